@@ -0,0 +1,104 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cep parses, normalizes and formats Brazilian postal codes (CEP).
+//
+// The canonical form of a CEP is its 8 digits without punctuation
+// (e.g. "74323240"), which is what storage and upstream providers use as a
+// key. The hyphenated form (e.g. "74323-240") is meant for display only.
+package cep
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+)
+
+// Length is the number of digits of a CEP.
+const Length = 8
+
+// CEP is a postal code in its canonical 8 digit form.
+type CEP string
+
+// Parse normalizes s into a CEP. Surrounding spaces, a hyphen before the last
+// three digits and a dot after the first two are accepted, so "74323240",
+// "74323-240", "74.323-240" and " 74323240 " all parse to the same CEP.
+func Parse(s string) (CEP, error) {
+	const op errors.Op = "cep.Parse"
+
+	value := strings.TrimSpace(s)
+	if len(value) == Length+2 && value[2] == '.' {
+		value = value[:2] + value[3:]
+	}
+	if len(value) == Length+1 && value[5] == '-' {
+		value = value[:5] + value[6:]
+	}
+
+	if len(value) != Length {
+		return "", errors.E(op, errors.KindBadRequest, fmt.Sprintf("invalid cep %q, must have %d digits", s, Length))
+	}
+
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return "", errors.E(op, errors.KindBadRequest, fmt.Sprintf("invalid cep %q, must have only digits", s))
+		}
+	}
+
+	if value == "00000000" {
+		return "", errors.E(op, errors.KindBadRequest, fmt.Sprintf("invalid cep %q", s))
+	}
+
+	return CEP(value), nil
+}
+
+// MustParse is like Parse but panics if s is not a valid CEP.
+func MustParse(s string) CEP {
+	c, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// Normalize returns the canonical 8 digit form of s.
+func Normalize(s string) (string, error) {
+	c, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	return c.String(), nil
+}
+
+// Valid reports whether s can be parsed as a CEP.
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// String returns the canonical 8 digit form (e.g. "74323240").
+func (c CEP) String() string {
+	return string(c)
+}
+
+// Format returns the hyphenated form (e.g. "74323-240").
+func (c CEP) Format() string {
+	if len(c) != Length {
+		return string(c)
+	}
+
+	return string(c[:5]) + "-" + string(c[5:])
+}
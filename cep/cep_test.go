@@ -0,0 +1,51 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cep
+
+import (
+	"testing"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for _, input := range []string{"74323240", "74323-240", " 74323240", "74323240 ", "74.323-240", "\t74323-240\n"} {
+		c, err := Parse(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, CEP("74323240"), c, input)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, input := range []string{"", "7432324", "743232400", "7432-3240", "74323 240", "7432324a", "abcdefgh", "00000000", "00000-000", "74.323240", "７４３２３２４０"} {
+		_, err := Parse(input)
+		assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest for %q, got %v", input, err)
+		assert.False(t, Valid(input), input)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	c := MustParse("74323240")
+	assert.Equal(t, "74323240", c.String())
+	assert.Equal(t, "74323-240", c.Format())
+
+	n, err := Normalize("74323-240")
+	require.NoError(t, err)
+	assert.Equal(t, "74323240", n)
+
+	assert.Panics(t, func() { MustParse("invalid") })
+}
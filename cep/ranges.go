@@ -0,0 +1,102 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cep
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+)
+
+// Range is an interval of CEPs assigned to a state (UF).
+type Range struct {
+	State string `json:"state"`
+	Start CEP    `json:"start"`
+	End   CEP    `json:"end"`
+}
+
+// Contains reports whether c falls within the range.
+func (r Range) Contains(c CEP) bool {
+	return c >= r.Start && c <= r.End
+}
+
+//go:embed ranges.csv
+var rangesCSV []byte
+
+// ranges is sorted by Start and has no overlaps.
+var ranges = mustLoadRanges(rangesCSV)
+
+func mustLoadRanges(data []byte) []Range {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		panic(err)
+	}
+
+	result := make([]Range, 0, len(records))
+	for _, record := range records[1:] {
+		r := Range{State: record[0], Start: MustParse(record[1]), End: MustParse(record[2])}
+		if r.End < r.Start {
+			panic(fmt.Sprintf("invalid cep range %v", r))
+		}
+		result = append(result, r)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start < result[j].Start
+	})
+
+	for i := 1; i < len(result); i++ {
+		if result[i].Start <= result[i-1].End {
+			panic(fmt.Sprintf("overlapping cep ranges %v and %v", result[i-1], result[i]))
+		}
+	}
+
+	return result
+}
+
+// Ranges returns the ranges assigned to every state, ordered by CEP.
+func Ranges() []Range {
+	result := make([]Range, len(ranges))
+	copy(result, ranges)
+	return result
+}
+
+// StateOf returns the state (UF) that owns c. It fails with KindNotFound
+// when c is outside all known ranges.
+func StateOf(c CEP) (string, error) {
+	const op errors.Op = "cep.StateOf"
+
+	i := sort.Search(len(ranges), func(i int) bool {
+		return ranges[i].End >= c
+	})
+
+	if i < len(ranges) && ranges[i].Contains(c) {
+		return ranges[i].State, nil
+	}
+
+	return "", errors.E(op, errors.KindNotFound, fmt.Sprintf("cep %s is outside all state ranges", c.Format()))
+}
+
+// MatchesState reports whether c belongs to the given state. The comparison
+// is case-insensitive.
+func MatchesState(c CEP, state string) bool {
+	owner, err := StateOf(c)
+	return err == nil && strings.EqualFold(owner, state)
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cep
+
+import (
+	"testing"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateOf(t *testing.T) {
+	tests := map[string]string{
+		"01000000": "SP",
+		"01003900": "SP",
+		"19999999": "SP",
+		"20000000": "RJ",
+		"69350000": "RR",
+		"69400000": "AM",
+		"70000000": "DF",
+		"72800000": "GO",
+		"73000000": "DF",
+		"74323240": "GO",
+		"75170000": "GO",
+		"76800000": "RO",
+		"99999999": "RS",
+	}
+
+	for input, expected := range tests {
+		state, err := StateOf(MustParse(input))
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, state, input)
+	}
+}
+
+func TestStateOfOutsideRanges(t *testing.T) {
+	for _, input := range []string{"00000001", "00999999"} {
+		_, err := StateOf(MustParse(input))
+		assert.True(t, errors.Is(err, errors.KindNotFound), input)
+	}
+}
+
+func TestMatchesState(t *testing.T) {
+	assert.True(t, MatchesState(MustParse("74323240"), "GO"))
+	assert.True(t, MatchesState(MustParse("74323240"), "go"))
+	assert.False(t, MatchesState(MustParse("74323240"), "SP"))
+	assert.False(t, MatchesState(MustParse("00000001"), "SP"))
+}
+
+func TestRanges(t *testing.T) {
+	r := Ranges()
+	require.NotEmpty(t, r)
+	for i := 1; i < len(r); i++ {
+		assert.Less(t, r[i-1].End, r[i].Start)
+	}
+}
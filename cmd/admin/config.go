@@ -15,12 +15,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/insighted4/correios-cep/correios"
 	"github.com/insighted4/correios-cep/pkg/log"
 	"github.com/insighted4/correios-cep/pkg/net"
 	"github.com/insighted4/correios-cep/server"
 	"github.com/insighted4/correios-cep/storage"
+	"github.com/insighted4/correios-cep/storage/memory"
+	"github.com/insighted4/correios-cep/storage/postgres"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -30,13 +38,122 @@ func newLogger() logrus.FieldLogger {
 	return log.New(viper.GetString("log_level"), viper.GetString("log_format"))
 }
 
-func newServerConfig(storage storage.Storage) server.Config {
+func newServerConfig(storage storage.Storage, provider correios.Correios) server.Config {
 	return server.Config{
 		HTTPServerConfig: net.HTTPServerConfig{
 			Addr: viper.GetString("addr"),
 		},
 		ReleaseMode: viper.GetString("log_level") != "debug",
-		Storage:     storage,
+		APIToken:    viper.GetString("api_token"),
+		NotFoundTTL: viper.GetDuration("not_found_ttl"),
+		MaxAge:      viper.GetDuration("max_age"),
+
+		StaleWhileRevalidate: viper.GetBool("stale_while_revalidate"),
+		RefreshInterval:      viper.GetDuration("refresh_interval"),
+		RefreshBatchSize:     viper.GetInt("refresh_batch_size"),
+
+		Storage:  storage,
+		Correios: provider,
+	}
+}
+
+func newCorreios() (correios.Correios, error) {
+	opts := newCorreiosOptions()
+	correios.Register("correios", func() (correios.Correios, error) {
+		return correios.New(opts), nil
+	})
+
+	if datasetDir := viper.GetString("dataset_dir"); datasetDir != "" {
+		correios.Register("dataset", func() (correios.Correios, error) {
+			return correios.NewDataset(os.DirFS(datasetDir))
+		})
+	}
+
+	// Environment variables arrive as a single comma separated value.
+	var names []string
+	for _, value := range viper.GetStringSlice("providers") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	limiter := correios.LimiterOptions{
+		Rate:        viper.GetFloat64("rate_limit"),
+		Burst:       viper.GetInt("rate_burst"),
+		MaxInFlight: viper.GetInt("max_in_flight"),
+		MaxWait:     viper.GetDuration("rate_wait"),
+	}
+
+	// Each provider gets its own limits and breaker, so the chain skips the
+	// broken or saturated ones. Cassettes are recorded inside them, so they
+	// only hold what the provider itself answered.
+	cassetteDir := viper.GetString("cassette_dir")
+	providers := make([]correios.Correios, 0, len(names))
+	for _, name := range names {
+		provider, err := correios.NewProvider(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if cassetteDir != "" {
+			provider, err = correios.NewRecorder(provider, filepath.Join(cassetteDir, name), correios.RecorderMode(viper.GetString("cassette_mode")))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if limiter.Rate > 0 || limiter.MaxInFlight > 0 {
+			provider = correios.NewLimiter(name, provider, limiter)
+		}
+
+		if threshold := viper.GetInt("breaker_threshold"); threshold > 0 {
+			provider = correios.NewBreaker(name, provider, correios.BreakerOptions{
+				Threshold: threshold,
+				Timeout:   viper.GetDuration("breaker_timeout"),
+			})
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return correios.NewChain(providers...), nil
+}
+
+func newCorreiosOptions() correios.Options {
+	// On the command line, 0 means no retries rather than the default.
+	retries := viper.GetInt("correios_retries")
+	if retries == 0 {
+		retries = correios.NoRetries
+	}
+
+	return correios.Options{
+		BaseURL:      viper.GetString("correios_url"),
+		Path:         viper.GetString("correios_path"),
+		SearchPath:   viper.GetString("correios_search_path"),
+		Timeout:      viper.GetDuration("correios_timeout"),
+		Retries:      retries,
+		RetryWait:    viper.GetDuration("correios_retry_wait"),
+		RetryMaxWait: viper.GetDuration("correios_retry_max_wait"),
+		UserAgent:    viper.GetString("correios_user_agent"),
+		Proxy:        viper.GetString("correios_proxy"),
+	}
+}
+
+func newStorage(ctx context.Context, now func() time.Time) (storage.Storage, error) {
+	switch viper.GetString("storage") {
+	case "", "postgres":
+		pgCfg, err := newPostgresOptions()
+		if err != nil {
+			return nil, err
+		}
+
+		return postgres.Connect(ctx, pgCfg, now)
+	case "memory":
+		return memory.New(now), nil
+	default:
+		return nil, fmt.Errorf("invalid storage %q", viper.GetString("storage"))
 	}
 }
 
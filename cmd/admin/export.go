@@ -0,0 +1,108 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/insighted4/correios-cep/export"
+	"github.com/insighted4/correios-cep/pkg/log"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/spf13/cobra"
+)
+
+func commandExport() *cobra.Command {
+	var (
+		format string
+		state  string
+		output string
+	)
+
+	formats := make([]string, 0, len(export.Formats()))
+	for _, f := range export.Formats() {
+		formats = append(formats, f.String())
+	}
+
+	cmd := cobra.Command{
+		Use:     "export",
+		Short:   "Export the stored addresses",
+		Example: fmt.Sprintf("%s export --format parquet --state GO -o addresses-go.parquet", shortDescription),
+		Args:    cobra.NoArgs,
+		PreRun:  bindStorageFlags,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := exportAddresses(format, state, output); err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	addStorageFlags(&cmd)
+	cmd.Flags().StringVar(&format, "format", export.FormatCSV.String(), fmt.Sprintf("output format (%s)", strings.Join(formats, ", ")))
+	cmd.Flags().StringVar(&state, "state", "", "export only the addresses of a state (e.g. GO)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file to write to (default: stdout)")
+
+	return &cmd
+}
+
+func exportAddresses(format, state, output string) error {
+	log.SetLogger(newLogger())
+
+	f, err := export.ParseFormat(format)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	s, err := newStorage(ctx, time.Now().UTC)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	w := os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		w = file
+	}
+
+	start := time.Now()
+	n, err := export.Write(w, f, s.ExportAddresses(ctx, storage.ExportParams{State: state}))
+	if err != nil {
+		return err
+	}
+
+	if output != "" {
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Exported %d addresses in %s", n, time.Since(start).Round(time.Second))
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/insighted4/correios-cep/correios"
+	"github.com/insighted4/correios-cep/correios/correiostest"
+	"github.com/insighted4/correios-cep/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+func commandFakeCorreios() *cobra.Command {
+	var (
+		addr     string
+		fixtures string
+		path     string
+		opts     correiostest.Options
+	)
+
+	cmd := cobra.Command{
+		Use:     "fake-correios",
+		Short:   "Serve a fake Correios lookup endpoint from fixture files",
+		Example: fmt.Sprintf("%s fake-correios --fixtures docs/samples --latency 200ms", shortDescription),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := fakeCorreios(addr, fixtures, path, opts); err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", ":8081", "HTTP bind address")
+	cmd.Flags().StringVar(&fixtures, "fixtures", "docs/samples", "directory of JSON fixtures, one per CEP")
+	cmd.Flags().StringVar(&path, "path", correios.DefaultPath, "path of the lookup endpoint")
+	cmd.Flags().DurationVar(&opts.Latency, "latency", 0, "delay every answer")
+	cmd.Flags().IntVar(&opts.Status, "status", 0, "answer every request with this HTTP status")
+	cmd.Flags().BoolVar(&opts.Empty, "empty", false, "answer every lookup with no records")
+	cmd.Flags().IntVar(&opts.Multi, "multi", 0, "repeat the records of every answer this many times")
+
+	return &cmd
+}
+
+func fakeCorreios(addr, fixtures, path string, opts correiostest.Options) error {
+	log.SetLogger(newLogger())
+
+	h, err := correiostest.NewHandler(os.DirFS(fixtures), path, opts)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Serving fake Correios from %s on %s%s", fixtures, addr, path)
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return srv.ListenAndServe()
+}
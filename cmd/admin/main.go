@@ -43,6 +43,10 @@ func commandRoot() *cobra.Command {
 	viper.AutomaticEnv()
 
 	rootCmd.AddCommand(commandServe())
+	rootCmd.AddCommand(commandFakeCorreios())
+	rootCmd.AddCommand(commandImport())
+	rootCmd.AddCommand(commandExport())
+	rootCmd.AddCommand(commandMigrate())
 	rootCmd.AddCommand(newVersion(longDescription))
 
 	return rootCmd
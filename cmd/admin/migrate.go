@@ -0,0 +1,208 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/insighted4/correios-cep/migrations"
+	"github.com/insighted4/correios-cep/pkg/log"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/insighted4/correios-cep/storage/postgres"
+	"github.com/spf13/cobra"
+)
+
+func commandMigrate() *cobra.Command {
+	cmd := cobra.Command{
+		Use:   "migrate",
+		Short: "Manage the PostgreSQL schema migrations",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+			os.Exit(2)
+		},
+	}
+
+	cmd.AddCommand(commandMigrateUp())
+	cmd.AddCommand(commandMigrateDown())
+	cmd.AddCommand(commandMigrateStatus())
+
+	return &cmd
+}
+
+func commandMigrateUp() *cobra.Command {
+	cmd := cobra.Command{
+		Use:     "up",
+		Short:   "Apply the pending migrations",
+		Example: fmt.Sprintf("%s migrate up --database-url \"$DATABASE_URL\"", shortDescription),
+		Args:    cobra.NoArgs,
+		PreRun:  bindStorageFlags,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := migrateUp(); err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	addStorageFlags(&cmd)
+
+	return &cmd
+}
+
+func commandMigrateDown() *cobra.Command {
+	var steps int
+
+	cmd := cobra.Command{
+		Use:     "down",
+		Short:   "Revert the last applied migrations",
+		Example: fmt.Sprintf("%s migrate down --steps 2", shortDescription),
+		Args:    cobra.NoArgs,
+		PreRun:  bindStorageFlags,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := migrateDown(steps); err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	addStorageFlags(&cmd)
+	cmd.Flags().IntVar(&steps, "steps", 1, "number of migrations to revert")
+
+	return &cmd
+}
+
+func commandMigrateStatus() *cobra.Command {
+	cmd := cobra.Command{
+		Use:     "status",
+		Short:   "List the migrations and whether they were applied",
+		Example: fmt.Sprintf("%s migrate status", shortDescription),
+		Args:    cobra.NoArgs,
+		PreRun:  bindStorageFlags,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := migrateStatus(); err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	addStorageFlags(&cmd)
+
+	return &cmd
+}
+
+func migrateUp() error {
+	return withMigrations(func(ctx context.Context, pg *postgres.Postgres, all []migrations.Migration) error {
+		applied, err := pg.MigrateUp(ctx, all)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Applied %d migrations", len(applied))
+		return nil
+	})
+}
+
+func migrateDown(steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("invalid steps %d", steps)
+	}
+
+	return withMigrations(func(ctx context.Context, pg *postgres.Postgres, all []migrations.Migration) error {
+		reverted, err := pg.MigrateDown(ctx, all, steps)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Reverted %d migrations", len(reverted))
+		return nil
+	})
+}
+
+func migrateStatus() error {
+	return withMigrations(func(ctx context.Context, pg *postgres.Postgres, all []migrations.Migration) error {
+		statuses, err := pg.MigrationStatus(ctx, all)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			_, _ = fmt.Fprintf(w, "%04d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+
+		return w.Flush()
+	})
+}
+
+// withMigrations connects to PostgreSQL, whatever the storage setting, and
+// calls fn with the embedded migrations.
+func withMigrations(fn func(ctx context.Context, pg *postgres.Postgres, all []migrations.Migration) error) error {
+	log.SetLogger(newLogger())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	all, err := migrations.All()
+	if err != nil {
+		return err
+	}
+
+	pgCfg, err := newPostgresOptions()
+	if err != nil {
+		return err
+	}
+
+	pg, err := postgres.Connect(ctx, pgCfg, time.Now().UTC)
+	if err != nil {
+		return err
+	}
+	defer pg.Close()
+
+	return fn(ctx, pg, all)
+}
+
+// autoMigrate applies the pending migrations when s is PostgreSQL.
+func autoMigrate(ctx context.Context, s storage.Storage) error {
+	pg, ok := s.(*postgres.Postgres)
+	if !ok {
+		return nil
+	}
+
+	all, err := migrations.All()
+	if err != nil {
+		return err
+	}
+
+	applied, err := pg.MigrateUp(ctx, all)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Applied %d migrations", len(applied))
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correios
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/pkg/log"
+	"github.com/insighted4/correios-cep/pkg/net"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	brasilAPIBaseURL  = "https://brasilapi.com.br"
+	brasilAPICheckURL = "/api/cep/v1/01001000"
+)
+
+type brasilAPI struct {
+	cli    *resty.Client
+	logger logrus.FieldLogger
+}
+
+var _ Correios = (*brasilAPI)(nil)
+
+// NewBrasilAPI returns a provider backed by the BrasilAPI CEP endpoint.
+func NewBrasilAPI() Correios {
+	logger := log.WithField("component", "brasilapi")
+	cli := net.NewClient().
+		SetBaseURL(brasilAPIBaseURL).
+		SetHeader("Accept", "application/json").
+		SetLogger(logger).
+		SetRetryCount(3).
+		SetTimeout(20 * time.Second)
+
+	return &brasilAPI{
+		cli:    cli,
+		logger: logger,
+	}
+}
+
+func (b *brasilAPI) Check(ctx context.Context) error {
+	const op errors.Op = "brasilapi.Check"
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	resp, err := b.cli.R().SetContext(ctx).Get(brasilAPICheckURL)
+	if err != nil {
+		b.logger.Errorf("failed to check BrasilAPI: %v", err)
+		return errors.E(op, errors.KindUnexpected, err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		b.logger.Errorf("failed to check BrasilAPI: unexpected status code %d", resp.StatusCode())
+		return errors.E(op, errors.KindUnexpected, fmt.Sprintf("GET %s returned %d", brasilAPICheckURL, resp.StatusCode()))
+	}
+
+	return nil
+}
+
+type brasilAPIResponse struct {
+	CEP          string `json:"cep"`
+	State        string `json:"state"`
+	City         string `json:"city"`
+	Neighborhood string `json:"neighborhood"`
+	Street       string `json:"street"`
+}
+
+func (r *brasilAPIResponse) toAddress() *storage.Address {
+	return &storage.Address{
+		CEP:          onlyDigits(r.CEP),
+		State:        r.State,
+		City:         r.City,
+		Neighborhood: r.Neighborhood,
+		Location:     r.Street,
+		Street:       r.Street,
+	}
+}
+
+func (b *brasilAPI) Lookup(ctx context.Context, cep string) (*storage.Address, error) {
+	const op errors.Op = "brasilapi.Lookup"
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	path := fmt.Sprintf("/api/cep/v1/%s", cep)
+	response := new(brasilAPIResponse)
+	resp, err := b.cli.R().SetContext(ctx).SetResult(response).Get(path)
+	if err != nil {
+		b.logger.Errorf("failed to lookup address: %v", err)
+		return nil, errors.E(op, errors.KindUnexpected, err)
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusOK:
+		return response.toAddress(), nil
+	case http.StatusNotFound:
+		return nil, errors.E(op, errors.KindNotFound, fmt.Sprintf("cep %s not found", cep))
+	case http.StatusBadRequest:
+		return nil, errors.E(op, errors.KindBadRequest, fmt.Sprintf("invalid cep %s", cep))
+	default:
+		b.logger.Errorf("failed to lookup address: unexpected status code %d", resp.StatusCode())
+		return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("GET %s returned %d", path, resp.StatusCode()))
+	}
+}
+
+// Search is not offered by BrasilAPI.
+func (b *brasilAPI) Search(ctx context.Context, query SearchQuery) ([]*storage.Address, error) {
+	const op errors.Op = "brasilapi.Search"
+	return nil, errors.E(op, errors.KindNotImplemented, "brasilapi does not search addresses")
+}
@@ -0,0 +1,229 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correios
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/pkg/log"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerTimeout   = 30 * time.Second
+)
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState string
+
+const (
+	// BreakerClosed lets every lookup through.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen fails every lookup without calling the provider.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single trial lookup through to decide whether
+	// the provider recovered.
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+type BreakerOptions struct {
+	// Threshold is the number of consecutive unexpected failures that opens
+	// the breaker. Defaults to DefaultBreakerThreshold.
+	Threshold int
+
+	// Timeout is how long the breaker stays open before a trial lookup is
+	// allowed. Defaults to DefaultBreakerTimeout.
+	Timeout time.Duration
+
+	// If specified, the breaker will use this function for determining time.
+	Now func() time.Time
+}
+
+// BreakerDetails reports the state of a circuit breaker in health checks.
+type BreakerDetails struct {
+	Name     string       `json:"name"`
+	State    BreakerState `json:"state"`
+	Failures int          `json:"failures"`
+	OpenedAt *time.Time   `json:"opened_at,omitempty"`
+}
+
+// Breaker is a circuit breaker around a provider. After Threshold
+// consecutive unexpected failures, lookups fail fast with KindUnavailable
+// until Timeout has passed and a trial lookup succeeds.
+type Breaker struct {
+	name     string
+	provider Correios
+	opts     BreakerOptions
+	logger   logrus.FieldLogger
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+var _ Correios = (*Breaker)(nil)
+
+func NewBreaker(name string, provider Correios, opts BreakerOptions) *Breaker {
+	if opts.Threshold < 1 {
+		opts.Threshold = DefaultBreakerThreshold
+	}
+
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultBreakerTimeout
+	}
+
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return &Breaker{
+		name:     name,
+		provider: provider,
+		opts:     opts,
+		logger:   log.WithFields(logrus.Fields{"component": "correios.breaker", "provider": name}),
+		state:    BreakerClosed,
+	}
+}
+
+// Check probes the provider regardless of the breaker state.
+func (b *Breaker) Check(ctx context.Context) error {
+	return b.provider.Check(ctx)
+}
+
+func (b *Breaker) Lookup(ctx context.Context, cep string) (*storage.Address, error) {
+	const op errors.Op = "correios.Breaker.Lookup"
+
+	trial, ok := b.allow()
+	if !ok {
+		return nil, errors.E(op, errors.KindUnavailable, fmt.Sprintf("provider %s is unavailable", b.name))
+	}
+
+	address, err := b.provider.Lookup(ctx, cep)
+	b.record(trial, failed(ctx, err))
+
+	return address, err
+}
+
+func (b *Breaker) Search(ctx context.Context, query SearchQuery) ([]*storage.Address, error) {
+	const op errors.Op = "correios.Breaker.Search"
+
+	trial, ok := b.allow()
+	if !ok {
+		return nil, errors.E(op, errors.KindUnavailable, fmt.Sprintf("provider %s is unavailable", b.name))
+	}
+
+	addresses, err := b.provider.Search(ctx, query)
+	b.record(trial, failed(ctx, err))
+
+	return addresses, err
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+func (b *Breaker) Details() interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	details := BreakerDetails{
+		Name:     b.name,
+		State:    b.state,
+		Failures: b.failures,
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		details.OpenedAt = &openedAt
+	}
+
+	return details
+}
+
+// allow reports whether a lookup may go through, and whether it is the
+// trial lookup of a half-open breaker.
+func (b *Breaker) allow() (trial, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.opts.Now().Sub(b.openedAt) < b.opts.Timeout {
+			return false, false
+		}
+
+		b.setState(BreakerHalfOpen)
+		fallthrough
+	case BreakerHalfOpen:
+		if b.trial {
+			return false, false
+		}
+
+		b.trial = true
+		return true, true
+	}
+
+	return false, true
+}
+
+// record updates the breaker with the outcome of a lookup. Lookups that
+// started before the breaker opened do not change its state.
+func (b *Breaker) record(trial, failure bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if trial {
+		b.trial = false
+	} else if b.state != BreakerClosed {
+		return
+	}
+
+	if !failure {
+		b.failures = 0
+		b.setState(BreakerClosed)
+		return
+	}
+
+	b.failures++
+	if trial || b.failures >= b.opts.Threshold {
+		b.openedAt = b.opts.Now()
+		b.setState(BreakerOpen)
+	}
+}
+
+func (b *Breaker) setState(state BreakerState) {
+	if b.state == state {
+		return
+	}
+
+	b.logger.Warnf("circuit breaker %s", state)
+	b.state = state
+}
+
+// failed reports whether err means the provider is failing. Answers such as
+// KindNotFound and lookups abandoned by the caller do not count.
+func failed(ctx context.Context, err error) bool {
+	return err != nil && errors.Is(err, errors.KindUnexpected) && ctx.Err() == nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correios
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	provider := &fakeProvider{err: errors.E("fake", errors.KindUnexpected, "down")}
+	b := NewBreaker("fake", provider, BreakerOptions{
+		Threshold: 2,
+		Timeout:   time.Minute,
+		Now:       func() time.Time { return now },
+	})
+
+	// Consecutive failures open the breaker.
+	for i := 0; i < 2; i++ {
+		_, err := b.Lookup(ctx, "74323240")
+		assert.True(t, errors.Is(err, errors.KindUnexpected), "expected KindUnexpected, got %v", err)
+	}
+	assert.Equal(t, BreakerOpen, b.State())
+
+	// While open, lookups fail fast.
+	_, err := b.Lookup(ctx, "74323240")
+	assert.True(t, errors.Is(err, errors.KindUnavailable), "expected KindUnavailable, got %v", err)
+	assert.Equal(t, 2, provider.calls)
+
+	// A failed trial opens it again.
+	now = now.Add(time.Minute)
+	_, err = b.Lookup(ctx, "74323240")
+	assert.True(t, errors.Is(err, errors.KindUnexpected), "expected KindUnexpected, got %v", err)
+	assert.Equal(t, BreakerOpen, b.State())
+	assert.Equal(t, 3, provider.calls)
+
+	// A successful trial closes it.
+	now = now.Add(time.Minute)
+	provider.err = nil
+	provider.address = &storage.Address{CEP: "74323240"}
+	address, err := b.Lookup(ctx, "74323240")
+	require.NoError(t, err)
+	assert.Equal(t, "74323240", address.CEP)
+	assert.Equal(t, BreakerClosed, b.State())
+
+	details := b.Details().(BreakerDetails)
+	assert.Equal(t, BreakerDetails{Name: "fake", State: BreakerClosed}, details)
+}
+
+func TestBreaker_IgnoresAnswers(t *testing.T) {
+	ctx := context.Background()
+	provider := &fakeProvider{err: errors.E("fake", errors.KindNotFound, "not found")}
+	b := NewBreaker("fake", provider, BreakerOptions{Threshold: 1})
+
+	for i := 0; i < 3; i++ {
+		_, err := b.Lookup(ctx, "74323240")
+		assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+	}
+	assert.Equal(t, BreakerClosed, b.State())
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	provider.err = errors.E("fake", errors.KindUnexpected, canceled.Err())
+	_, _ = b.Lookup(canceled, "74323240")
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestChain_FallbackOnOpenBreaker(t *testing.T) {
+	ctx := context.Background()
+	first := &fakeProvider{err: errors.E("first", errors.KindUnexpected, "down")}
+	second := &fakeProvider{address: &storage.Address{CEP: "74323240"}}
+	c := NewChain(NewBreaker("first", first, BreakerOptions{Threshold: 1}), second)
+
+	for i := 0; i < 3; i++ {
+		address, err := c.Lookup(ctx, "74323240")
+		require.NoError(t, err)
+		assert.Equal(t, "74323240", address.CEP)
+	}
+	assert.Equal(t, 1, first.calls)
+	assert.Equal(t, 3, second.calls)
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correios
+
+import (
+	"context"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/pkg/health"
+	"github.com/insighted4/correios-cep/pkg/log"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/sirupsen/logrus"
+)
+
+type chain struct {
+	providers []Correios
+	logger    logrus.FieldLogger
+}
+
+var _ Correios = (*chain)(nil)
+
+// NewChain returns a Correios that queries the given providers in priority
+// order. The next provider is only tried when the previous one fails with
+// an unexpected error, is unavailable or is rate limited, and the caller is
+// still waiting; any other answer, including KindNotFound, is final.
+func NewChain(providers ...Correios) Correios {
+	if len(providers) == 1 {
+		return providers[0]
+	}
+
+	return &chain{
+		providers: providers,
+		logger:    log.WithField("component", "correios.chain"),
+	}
+}
+
+// Check succeeds as long as at least one provider is healthy.
+func (c *chain) Check(ctx context.Context) error {
+	const op errors.Op = "correios.chain.Check"
+
+	if len(c.providers) == 0 {
+		return errors.E(op, errors.KindUnexpected, "no providers configured")
+	}
+
+	var err error
+	for _, provider := range c.providers {
+		if err = provider.Check(ctx); err == nil {
+			return nil
+		}
+	}
+
+	return errors.E(op, err)
+}
+
+func (c *chain) Lookup(ctx context.Context, cep string) (*storage.Address, error) {
+	const op errors.Op = "correios.chain.Lookup"
+
+	if len(c.providers) == 0 {
+		return nil, errors.E(op, errors.KindUnexpected, "no providers configured")
+	}
+
+	var err error
+	for i, provider := range c.providers {
+		var address *storage.Address
+		address, err = provider.Lookup(ctx, cep)
+		if !shouldFallback(err) || ctx.Err() != nil {
+			return address, err
+		}
+
+		if i < len(c.providers)-1 {
+			c.logger.Warnf("provider %d failed to lookup cep %s, falling back: %v", i, cep, err)
+		}
+	}
+
+	return nil, errors.E(op, err)
+}
+
+// Search falls back like Lookup, and also when a provider does not search
+// addresses.
+func (c *chain) Search(ctx context.Context, query SearchQuery) ([]*storage.Address, error) {
+	const op errors.Op = "correios.chain.Search"
+
+	if len(c.providers) == 0 {
+		return nil, errors.E(op, errors.KindUnexpected, "no providers configured")
+	}
+
+	var err error
+	for i, provider := range c.providers {
+		var addresses []*storage.Address
+		addresses, err = provider.Search(ctx, query)
+		if !shouldFallback(err) && !errors.Is(err, errors.KindNotImplemented) || ctx.Err() != nil {
+			return addresses, err
+		}
+
+		if i < len(c.providers)-1 {
+			c.logger.Warnf("provider %d failed to search %s, falling back: %v", i, query, err)
+		}
+	}
+
+	return nil, errors.E(op, err)
+}
+
+// Details reports the details of the providers that have any, such as
+// circuit breakers.
+func (c *chain) Details() interface{} {
+	details := make([]interface{}, 0, len(c.providers))
+	for _, provider := range c.providers {
+		if detailer, ok := provider.(health.Detailer); ok {
+			details = append(details, detailer.Details())
+		}
+	}
+
+	return details
+}
+
+func shouldFallback(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch errors.Kind(err) {
+	case errors.KindUnexpected, errors.KindUnavailable, errors.KindRateLimit:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,153 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correios
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProvider struct {
+	address *storage.Address
+	err     error
+	calls   int
+}
+
+func (f *fakeProvider) Check(ctx context.Context) error {
+	return f.err
+}
+
+func (f *fakeProvider) Lookup(ctx context.Context, cep string) (*storage.Address, error) {
+	f.calls++
+	return f.address, f.err
+}
+
+func (f *fakeProvider) Search(ctx context.Context, query SearchQuery) ([]*storage.Address, error) {
+	f.calls++
+	if f.address == nil {
+		return nil, f.err
+	}
+
+	return []*storage.Address{f.address}, f.err
+}
+
+func TestChain_Fallback(t *testing.T) {
+	first := &fakeProvider{err: errors.E("first", errors.KindUnexpected, "down")}
+	second := &fakeProvider{address: &storage.Address{CEP: "74323240"}}
+
+	address, err := NewChain(first, second).Lookup(context.Background(), "74323240")
+	require.NoError(t, err)
+	assert.Equal(t, "74323240", address.CEP)
+	assert.Equal(t, 1, first.calls)
+	assert.Equal(t, 1, second.calls)
+}
+
+func TestChain_NotFoundIsFinal(t *testing.T) {
+	first := &fakeProvider{err: errors.E("first", errors.KindNotFound, "not found")}
+	second := &fakeProvider{address: &storage.Address{CEP: "74323240"}}
+
+	_, err := NewChain(first, second).Lookup(context.Background(), "74323240")
+	assert.True(t, errors.Is(err, errors.KindNotFound))
+	assert.Equal(t, 0, second.calls)
+}
+
+func TestChain_AllFail(t *testing.T) {
+	first := &fakeProvider{err: errors.E("first", errors.KindUnexpected, "down")}
+	second := &fakeProvider{err: errors.E("second", errors.KindUnexpected, "down")}
+
+	c := NewChain(first, second)
+	_, err := c.Lookup(context.Background(), "74323240")
+	assert.True(t, errors.Is(err, errors.KindUnexpected))
+	assert.Error(t, c.Check(context.Background()))
+}
+
+func TestChain_StopsWhenCanceled(t *testing.T) {
+	first := &fakeProvider{err: errors.E("first", errors.KindUnexpected, context.Canceled)}
+	second := &fakeProvider{address: &storage.Address{CEP: "74323240"}}
+
+	// A caller giving up says nothing about the provider, so the others
+	// are not spent on it.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := NewChain(first, second)
+	_, err := c.Lookup(ctx, "74323240")
+	assert.True(t, errors.Is(err, errors.KindUnexpected), "expected KindUnexpected, got %v", err)
+
+	_, err = c.Search(ctx, SearchQuery{State: "GO", City: "Goiânia", Street: "Anhanguera"})
+	assert.True(t, errors.Is(err, errors.KindUnexpected), "expected KindUnexpected, got %v", err)
+	assert.Equal(t, 0, second.calls)
+}
+
+func TestChain_CheckAnyHealthy(t *testing.T) {
+	first := &fakeProvider{err: errors.E("first", errors.KindUnexpected, "down")}
+	second := &fakeProvider{}
+
+	assert.NoError(t, NewChain(first, second).Check(context.Background()))
+}
+
+func TestNewProvider(t *testing.T) {
+	Register("fake", func() (Correios, error) { return &fakeProvider{}, nil })
+
+	p, err := NewProvider("fake", "correios")
+	require.NoError(t, err)
+	assert.NotNil(t, p)
+
+	_, err = NewProvider("unknown")
+	assert.True(t, errors.Is(err, errors.KindBadRequest))
+
+	_, err = NewProvider()
+	assert.True(t, errors.Is(err, errors.KindBadRequest))
+}
+
+func TestDataset_Lookup(t *testing.T) {
+	d, err := NewDataset(os.DirFS("../docs/samples"))
+	require.NoError(t, err)
+
+	address, err := d.Lookup(context.Background(), "74323-240")
+	require.NoError(t, err)
+	assert.Equal(t, "GO", address.State)
+	assert.Equal(t, "Avenida General Couto de Magalhães", address.Location)
+	assert.Equal(t, storage.CEPTypeStreet, address.Type)
+
+	address, err = d.Lookup(context.Background(), "74691550")
+	require.NoError(t, err)
+	assert.Len(t, address.Children, 6)
+
+	address, err = d.Lookup(context.Background(), "01003900")
+	require.NoError(t, err)
+	assert.Equal(t, "Edifício Triângulo", address.Unit)
+	assert.Equal(t, storage.CEPTypeBigUser, address.Type)
+
+	address, err = d.Lookup(context.Background(), "74001970")
+	require.NoError(t, err)
+	assert.Equal(t, storage.CEPTypeUnit, address.Type)
+	assert.Equal(t, []storage.POBoxRange{{Start: "1", End: "1200"}}, address.POBoxRanges)
+
+	// Answers are copies the caller may change.
+	address.POBoxRanges[0].End = "1"
+	address, err = d.Lookup(context.Background(), "74001970")
+	require.NoError(t, err)
+	assert.Equal(t, "1200", address.POBoxRanges[0].End)
+
+	_, err = d.Lookup(context.Background(), "00000000")
+	assert.True(t, errors.Is(err, errors.KindNotFound))
+}
@@ -20,7 +20,13 @@ import (
 	"github.com/insighted4/correios-cep/storage"
 )
 
+// Correios is implemented by every upstream address provider. Besides the
+// Correios website itself, providers can be combined with NewChain so that
+// alternative sources are used when one of them is unavailable.
 type Correios interface {
 	Check(ctx context.Context) error
 	Lookup(ctx context.Context, cep string) (*storage.Address, error)
+	// Search finds the addresses matching a street in a city. Providers
+	// without address search fail with KindNotImplemented.
+	Search(ctx context.Context, query SearchQuery) ([]*storage.Address, error)
 }
@@ -25,33 +25,126 @@ import (
 	"github.com/insighted4/correios-cep/pkg/log"
 	"github.com/insighted4/correios-cep/pkg/net"
 	"github.com/insighted4/correios-cep/storage"
+	"github.com/insighted4/correios-cep/street"
 	"github.com/sirupsen/logrus"
 )
 
 const (
-	baseURL   = "https://buscacepinter.correios.com.br"
-	lookupURL = "/app/consulta/html/consulta-detalhes-cep.php"
+	DefaultBaseURL      = "https://buscacepinter.correios.com.br"
+	DefaultPath         = "/app/consulta/html/consulta-detalhes-cep.php"
+	DefaultSearchPath   = "/app/localidade_logradouro/carrega-localidade-logradouro.php"
+	DefaultTimeout      = 30 * time.Second
+	DefaultRetries      = 3
+	DefaultRetryWait    = 100 * time.Millisecond
+	DefaultRetryMaxWait = 2 * time.Second
+
+	// NoRetries disables retries, since a zero Retries takes the default.
+	NoRetries = -1
 )
 
+// Options configure the Correios client. Zero values take the defaults.
+type Options struct {
+	// BaseURL of the Correios website, or of a mirror or fake.
+	BaseURL string
+
+	// Path of the lookup endpoint.
+	Path string
+
+	// SearchPath of the address search endpoint.
+	SearchPath string
+
+	// Timeout bounds each call, retries included. Callers with an earlier
+	// deadline keep theirs.
+	Timeout time.Duration
+
+	// Retries is the number of retries after a request fails or is answered
+	// with a server error. Zero takes DefaultRetries; use NoRetries (or any
+	// negative value) to disable them.
+	Retries int
+
+	// RetryWait and RetryMaxWait bound the exponential backoff between
+	// retries.
+	RetryWait    time.Duration
+	RetryMaxWait time.Duration
+
+	// UserAgent sent upstream. Defaults to net.UserAgent.
+	UserAgent string
+
+	// Proxy is the URL of an HTTP proxy. Empty uses the environment.
+	Proxy string
+}
+
+func (o *Options) setDefaults() {
+	if o.BaseURL == "" {
+		o.BaseURL = DefaultBaseURL
+	}
+
+	if o.Path == "" {
+		o.Path = DefaultPath
+	}
+
+	if o.SearchPath == "" {
+		o.SearchPath = DefaultSearchPath
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+
+	switch {
+	case o.Retries == 0:
+		o.Retries = DefaultRetries
+	case o.Retries < 0:
+		o.Retries = 0
+	}
+
+	if o.RetryWait <= 0 {
+		o.RetryWait = DefaultRetryWait
+	}
+
+	if o.RetryMaxWait <= 0 {
+		o.RetryMaxWait = DefaultRetryMaxWait
+	}
+
+	if o.UserAgent == "" {
+		o.UserAgent = net.UserAgent
+	}
+}
+
 type client struct {
 	cli    *resty.Client
+	opts   Options
 	logger logrus.FieldLogger
 }
 
 var _ Correios = (*client)(nil)
 
-func New() Correios {
+// New returns a client for the Correios website.
+func New(opts Options) Correios {
+	opts.setDefaults()
+
 	logger := log.WithField("component", "correios")
 	cli := net.NewClient().
-		SetBaseURL(baseURL).
+		SetBaseURL(opts.BaseURL).
 		SetHeader("Accept", "application/json").
-		SetHeader("Referer", baseURL).
+		SetHeader("Referer", opts.BaseURL).
+		SetHeader("User-Agent", opts.UserAgent).
 		SetLogger(logger).
-		SetRetryCount(3).
-		SetTimeout(20 * time.Second)
+		SetRetryCount(opts.Retries).
+		SetRetryWaitTime(opts.RetryWait).
+		SetRetryMaxWaitTime(opts.RetryMaxWait).
+		AddRetryCondition(func(resp *resty.Response, err error) bool {
+			return resp != nil && resp.StatusCode() >= http.StatusInternalServerError
+		}).
+		SetTimeout(opts.Timeout)
+
+	if opts.Proxy != "" {
+		cli.SetProxy(opts.Proxy)
+	}
 
 	return &client{
 		cli:    cli,
+		opts:   opts,
 		logger: logger,
 	}
 }
@@ -59,7 +152,10 @@ func New() Correios {
 func (c *client) Check(ctx context.Context) error {
 	const op errors.Op = "correios.Check"
 
-	resp, err := c.cli.R().Head(lookupURL)
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	resp, err := c.cli.R().SetContext(ctx).Head(c.opts.Path)
 	if err != nil {
 		c.logger.Errorf("failed to check Correios: %v", err)
 		return errors.E(op, errors.KindUnexpected, err)
@@ -67,40 +163,92 @@ func (c *client) Check(ctx context.Context) error {
 
 	if resp.StatusCode() != http.StatusOK {
 		c.logger.Errorf("failed to check Correios: unexpected status code %d", resp.StatusCode)
-		return errors.E(op, errors.KindUnexpected, fmt.Sprintf("HEAD %s returned %d", lookupURL, resp.StatusCode()))
+		return errors.E(op, errors.KindUnexpected, fmt.Sprintf("HEAD %s returned %d", c.opts.Path, resp.StatusCode()))
 	}
 
 	return nil
 }
 
 type Dado struct {
-	UF                       string        `json:"uf"`
-	Localidade               string        `json:"localidade"`
-	LocNoSem                 string        `json:"locNoSem"`
-	LocNu                    string        `json:"locNu"`
-	LocalidadeSubordinada    string        `json:"localidadeSubordinada"`
-	LogradouroDNEC           string        `json:"logradouroDNEC"`
-	LogradouroTextoAdicional string        `json:"logradouroTextoAdicional"`
-	LogradouroTexto          string        `json:"logradouroTexto"`
-	Bairro                   string        `json:"bairro"`
-	BaiNu                    string        `json:"baiNu"`
-	NomeUnidade              string        `json:"nomeUnidade"`
-	CEP                      string        `json:"cep"`
-	TipoCEP                  string        `json:"tipoCep"`
-	NumeroLocalidade         string        `json:"numeroLocalidade"`
-	Situacao                 string        `json:"situacao"`
-	FaixasCaixaPostal        []interface{} `json:"faixasCaixaPostal"`
-	FaixasCEP                []interface{} `json:"faixasCep"`
+	UF                       string             `json:"uf"`
+	Localidade               string             `json:"localidade"`
+	LocNoSem                 string             `json:"locNoSem"`
+	LocNu                    string             `json:"locNu"`
+	LocalidadeSubordinada    string             `json:"localidadeSubordinada"`
+	LogradouroDNEC           string             `json:"logradouroDNEC"`
+	LogradouroTextoAdicional string             `json:"logradouroTextoAdicional"`
+	LogradouroTexto          string             `json:"logradouroTexto"`
+	Bairro                   string             `json:"bairro"`
+	BaiNu                    string             `json:"baiNu"`
+	NomeUnidade              string             `json:"nomeUnidade"`
+	CEP                      string             `json:"cep"`
+	TipoCEP                  string             `json:"tipoCep"`
+	NumeroLocalidade         string             `json:"numeroLocalidade"`
+	Situacao                 string             `json:"situacao"`
+	FaixasCaixaPostal        []FaixaCaixaPostal `json:"faixasCaixaPostal"`
+	FaixasCEP                []FaixaCEP         `json:"faixasCep"`
+}
+
+// FaixaCaixaPostal is a range of PO boxes served by a postal unit.
+type FaixaCaixaPostal struct {
+	CaixaInicial string `json:"caixaInicial"`
+	CaixaFinal   string `json:"caixaFinal"`
+}
+
+// FaixaCEP is a range of CEPs assigned to a locality.
+type FaixaCEP struct {
+	CEPInicial string `json:"cepInicial"`
+	CEPFinal   string `json:"cepFinal"`
+}
+
+// cepType maps the tipoCep code returned upstream into a storage.CEPType.
+func cepType(tipoCEP string) storage.CEPType {
+	switch tipoCEP {
+	case "1":
+		return storage.CEPTypeLocality
+	case "2":
+		return storage.CEPTypeStreet
+	case "3":
+		return storage.CEPTypeCommunityBox
+	case "5":
+		return storage.CEPTypeBigUser
+	case "6":
+		return storage.CEPTypeUnit
+	default:
+		return storage.CEPTypeUnknown
+	}
 }
 
 func (d *Dado) toAddress() *storage.Address {
-	return &storage.Address{
+	address := &storage.Address{
 		CEP:          d.CEP,
 		State:        d.UF,
 		City:         d.Localidade,
 		Neighborhood: d.Bairro,
 		Location:     d.LogradouroDNEC,
+		Unit:         d.NomeUnidade,
+		Type:         cepType(d.TipoCEP),
+		Complement:   d.LogradouroTextoAdicional,
+		Subordinate:  d.LocalidadeSubordinada,
+		Status:       d.Situacao,
 	}
+	address.Street, address.NumberRange = street.Parse(d.LogradouroDNEC)
+
+	for _, faixa := range d.FaixasCaixaPostal {
+		address.POBoxRanges = append(address.POBoxRanges, storage.POBoxRange{
+			Start: faixa.CaixaInicial,
+			End:   faixa.CaixaFinal,
+		})
+	}
+
+	for _, faixa := range d.FaixasCEP {
+		address.CEPRanges = append(address.CEPRanges, storage.CEPRange{
+			Start: faixa.CEPInicial,
+			End:   faixa.CEPFinal,
+		})
+	}
+
+	return address
 }
 
 type LookupResponse struct {
@@ -110,15 +258,37 @@ type LookupResponse struct {
 	Dados    []*Dado `json:"dados"`
 }
 
+// toAddress converts the response into an address. A CEP split into several
+// records is returned as a parent holding one child per record. It returns
+// nil when the response has no records.
+func (r *LookupResponse) toAddress(cep string) *storage.Address {
+	switch len(r.Dados) {
+	case 0:
+		return nil
+	case 1:
+		return r.Dados[0].toAddress()
+	}
+
+	address := &storage.Address{CEP: cep}
+	for _, dado := range r.Dados {
+		address.Children = append(address.Children, dado.toAddress())
+	}
+
+	return address
+}
+
 func (c *client) Lookup(ctx context.Context, cep string) (*storage.Address, error) {
 	const op errors.Op = "correios.Lookup"
 
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
 	form := map[string]string{
 		"cep": cep,
 	}
 
 	response := new(LookupResponse)
-	resp, err := c.cli.R().SetFormData(form).SetResult(&response).Post(lookupURL)
+	resp, err := c.cli.R().SetContext(ctx).SetFormData(form).SetResult(&response).Post(c.opts.Path)
 	if err != nil {
 		c.logger.Errorf("failed to lookup address: %v", err)
 		return nil, errors.E(op, errors.KindUnexpected, err)
@@ -126,21 +296,49 @@ func (c *client) Lookup(ctx context.Context, cep string) (*storage.Address, erro
 
 	if resp.StatusCode() != http.StatusOK {
 		c.logger.Errorf("failed to lookup address: unexpected status code %d", resp.StatusCode)
-		return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("POST %s returned %d", lookupURL, resp.StatusCode()))
+		return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("POST %s returned %d", c.opts.Path, resp.StatusCode()))
 	}
 
-	if len(response.Dados) == 0 {
+	address := response.toAddress(cep)
+	if address == nil {
 		return nil, errors.E(op, errors.KindNotFound, fmt.Sprintf("cep %s not found", cep))
 	}
 
-	address := &storage.Address{CEP: cep}
-	if len(response.Dados) == 1 {
-		return response.Dados[0].toAddress(), nil
+	return address, nil
+}
+
+func (c *client) Search(ctx context.Context, query SearchQuery) ([]*storage.Address, error) {
+	const op errors.Op = "correios.Search"
+
+	if err := query.Normalize(); err != nil {
+		return nil, errors.E(op, err)
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	form := map[string]string{
+		"uf":         query.State,
+		"localidade": query.City,
+		"logradouro": query.Street,
+	}
+
+	response := new(LookupResponse)
+	resp, err := c.cli.R().SetContext(ctx).SetFormData(form).SetResult(response).Post(c.opts.SearchPath)
+	if err != nil {
+		c.logger.Errorf("failed to search addresses: %v", err)
+		return nil, errors.E(op, errors.KindUnexpected, err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		c.logger.Errorf("failed to search addresses: unexpected status code %d", resp.StatusCode())
+		return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("POST %s returned %d", c.opts.SearchPath, resp.StatusCode()))
+	}
+
+	addresses := make([]*storage.Address, 0, len(response.Dados))
 	for _, dado := range response.Dados {
-		address.Children = append(address.Children, dado.toAddress())
+		addresses = append(addresses, dado.toAddress())
 	}
 
-	return address, nil
+	return addresses, nil
 }
@@ -0,0 +1,172 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correios
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Lookup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, DefaultPath, r.URL.Path)
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.FormValue("cep") != "01003900" {
+			_, _ = w.Write([]byte(`{"erro": false, "total": 0, "dados": []}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"erro": false, "total": 1, "dados": [{"uf": "SP", "localidade": "São Paulo", "cep": "01003900", "tipoCep": "5", "nomeUnidade": "Edifício Triângulo"}]}`))
+	}))
+	defer srv.Close()
+
+	c := New(Options{BaseURL: srv.URL})
+
+	address, err := c.Lookup(context.Background(), "01003900")
+	require.NoError(t, err)
+	assert.Equal(t, "Edifício Triângulo", address.Unit)
+
+	_, err = c.Lookup(context.Background(), "01003901")
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+}
+
+func TestClient_Search(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, DefaultSearchPath, r.URL.Path)
+		assert.Equal(t, "GO", r.FormValue("uf"))
+		assert.Equal(t, "Goiânia", r.FormValue("localidade"))
+		assert.Equal(t, "Couto de Magalhães", r.FormValue("logradouro"))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"erro": false, "total": 2, "dados": [` +
+			`{"uf": "GO", "localidade": "Goiânia", "cep": "74323240", "logradouroDNEC": "Avenida General Couto de Magalhães", "bairro": "Vila Mauá"},` +
+			`{"uf": "GO", "localidade": "Goiânia", "cep": "74323270", "logradouroDNEC": "Rua Couto de Magalhães", "bairro": "Vila Mauá"}]}`))
+	}))
+	defer srv.Close()
+
+	c := New(Options{BaseURL: srv.URL})
+
+	addresses, err := c.Search(context.Background(), SearchQuery{State: "go", City: " Goiânia", Street: "Couto de Magalhães "})
+	require.NoError(t, err)
+	require.Len(t, addresses, 2)
+	assert.Equal(t, "74323240", addresses[0].CEP)
+	assert.Equal(t, "Vila Mauá", addresses[1].Neighborhood)
+
+	_, err = c.Search(context.Background(), SearchQuery{State: "GO", City: "Goiânia", Street: "Av"})
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+}
+
+func TestClient_Options(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/mirror", r.URL.Path)
+		assert.Equal(t, "cep-test", r.UserAgent())
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	c := New(Options{BaseURL: srv.URL, Path: "/mirror", UserAgent: "cep-test", Retries: 2, RetryWait: time.Millisecond})
+	_, err := c.Lookup(context.Background(), "01003900")
+	assert.True(t, errors.Is(err, errors.KindUnexpected), "expected KindUnexpected, got %v", err)
+	assert.Equal(t, 3, requests)
+
+	requests = 0
+	c = New(Options{BaseURL: srv.URL, Path: "/mirror", UserAgent: "cep-test", Retries: NoRetries})
+	_, err = c.Lookup(context.Background(), "01003900")
+	assert.True(t, errors.Is(err, errors.KindUnexpected), "expected KindUnexpected, got %v", err)
+	assert.Equal(t, 1, requests)
+}
+
+// newBlockingServer returns a server whose requests only end when the
+// client goes away. Every request is sent on started, and canceled is
+// closed once the first request is abandoned.
+func newBlockingServer(t *testing.T) (srv *httptest.Server, started chan struct{}, canceled chan struct{}) {
+	t.Helper()
+
+	started = make(chan struct{}, 10)
+	canceled = make(chan struct{})
+
+	var once sync.Once
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The connection is only watched for closing once the body is read.
+		_, _ = io.Copy(io.Discard, r.Body)
+		started <- struct{}{}
+		<-r.Context().Done()
+		once.Do(func() { close(canceled) })
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, started, canceled
+}
+
+func TestClient_LookupCanceled(t *testing.T) {
+	srv, started, canceled := newBlockingServer(t)
+	c := New(Options{BaseURL: srv.URL})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := c.Lookup(ctx, "01003900")
+		done <- err
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("lookup was not aborted")
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("outbound request was not canceled")
+	}
+	assert.Len(t, started, 0, "canceled lookups must not be retried")
+}
+
+func TestClient_CheckDeadline(t *testing.T) {
+	srv, _, canceled := newBlockingServer(t)
+	c := New(Options{BaseURL: srv.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	assert.Error(t, c.Check(ctx))
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("outbound request was not canceled")
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package correiostest provides a fake of the Correios lookup endpoint,
+// serving fixture files in the format of docs/samples, for development and
+// integration tests without network access.
+package correiostest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/insighted4/correios-cep/correios"
+	"github.com/insighted4/correios-cep/pkg/errors"
+)
+
+// Options change how the fake answers. They can be switched while it runs.
+type Options struct {
+	// Latency delays every answer.
+	Latency time.Duration
+
+	// Status, when set to anything but 200, answers every request with that
+	// HTTP status and no body.
+	Status int
+
+	// Empty answers every lookup with no records, as Correios does for
+	// unknown CEPs.
+	Empty bool
+
+	// Multi answers every lookup with the records of its fixture repeated
+	// Multi times, as Correios does for CEPs split across several streets.
+	Multi int
+}
+
+// Handler serves the Correios lookup endpoint from fixtures.
+type Handler struct {
+	path      string
+	responses map[string]*correios.LookupResponse
+
+	mu       sync.RWMutex
+	opts     Options
+	requests int
+}
+
+// NewHandler loads the *.json fixtures of fsys, each named after the CEP it
+// answers (e.g. 74323-240.json), and serves them on endpoint, which defaults
+// to correios.DefaultPath.
+func NewHandler(fsys fs.FS, endpoint string, opts Options) (*Handler, error) {
+	const op errors.Op = "correiostest.NewHandler"
+
+	if endpoint == "" {
+		endpoint = correios.DefaultPath
+	}
+
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, errors.E(op, errors.KindUnexpected, err)
+	}
+
+	h := &Handler{
+		path:      endpoint,
+		responses: make(map[string]*correios.LookupResponse, len(files)),
+		opts:      opts,
+	}
+
+	for _, name := range files {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, errors.E(op, errors.KindUnexpected, err)
+		}
+
+		response, err := correios.DecodeLookupResponse(data)
+		if err != nil {
+			return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("failed to decode %s: %v", name, err))
+		}
+
+		h.responses[digits(strings.TrimSuffix(path.Base(name), path.Ext(name)))] = response
+	}
+
+	return h, nil
+}
+
+// NewServer starts a server answering from the fixtures of fsys. Callers
+// should Close it when done.
+func NewServer(fsys fs.FS, opts Options) (*httptest.Server, *Handler, error) {
+	h, err := NewHandler(fsys, "", opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return httptest.NewServer(h), h, nil
+}
+
+// SetOptions switches how the fake answers from now on.
+func (h *Handler) SetOptions(opts Options) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.opts = opts
+}
+
+// Requests returns the number of requests served so far.
+func (h *Handler) Requests() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.requests
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.requests++
+	opts := h.opts
+	h.mu.Unlock()
+
+	if r.URL.Path != h.path {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Reading the body lets the server notice clients going away.
+	_ = r.ParseForm()
+
+	if opts.Latency > 0 {
+		select {
+		case <-time.After(opts.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if opts.Status != 0 && opts.Status != http.StatusOK {
+		w.WriteHeader(opts.Status)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPost:
+		h.lookup(w, r, opts)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) lookup(w http.ResponseWriter, r *http.Request, opts Options) {
+	response := &correios.LookupResponse{Dados: []*correios.Dado{}}
+
+	if fixture, ok := h.responses[digits(r.FormValue("cep"))]; ok && !opts.Empty {
+		repeat := opts.Multi
+		if repeat < 1 {
+			repeat = 1
+		}
+
+		for i := 0; i < repeat; i++ {
+			response.Dados = append(response.Dados, fixture.Dados...)
+		}
+	}
+
+	response.Total = len(response.Dados)
+	if response.Total == 0 {
+		response.Mensagem = "DADOS NAO ENCONTRADOS"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func digits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
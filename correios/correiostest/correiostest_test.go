@@ -0,0 +1,71 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correiostest_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/insighted4/correios-cep/correios"
+	"github.com/insighted4/correios-cep/correios/correiostest"
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	srv, h, err := correiostest.NewServer(os.DirFS("../../docs/samples"), correiostest.Options{})
+	require.NoError(t, err)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := correios.New(correios.Options{BaseURL: srv.URL, Retries: correios.NoRetries})
+
+	require.NoError(t, c.Check(ctx))
+
+	address, err := c.Lookup(ctx, "01003900")
+	require.NoError(t, err)
+	assert.Equal(t, "Edifício Triângulo", address.Unit)
+	assert.Equal(t, storage.CEPTypeBigUser, address.Type)
+
+	_, err = c.Lookup(ctx, "01003901")
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+
+	h.SetOptions(correiostest.Options{Empty: true})
+	_, err = c.Lookup(ctx, "01003900")
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+
+	h.SetOptions(correiostest.Options{Multi: 2})
+	address, err = c.Lookup(ctx, "01003900")
+	require.NoError(t, err)
+	assert.Len(t, address.Children, 2)
+
+	h.SetOptions(correiostest.Options{Status: http.StatusServiceUnavailable})
+	_, err = c.Lookup(ctx, "01003900")
+	assert.True(t, errors.Is(err, errors.KindUnexpected), "expected KindUnexpected, got %v", err)
+	assert.Error(t, c.Check(ctx))
+
+	h.SetOptions(correiostest.Options{Latency: time.Second})
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = c.Lookup(timeout, "01003900")
+	assert.True(t, errors.Is(err, errors.KindUnexpected), "expected KindUnexpected, got %v", err)
+
+	assert.Equal(t, 8, h.Requests())
+}
@@ -0,0 +1,183 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correios
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+)
+
+type dataset struct {
+	addresses map[string]*storage.Address
+}
+
+var _ Correios = (*dataset)(nil)
+
+// NewDataset returns a provider that answers from local JSON files, one per
+// CEP, named after the CEP they describe (e.g. 74323-240.json). Each file
+// holds either a single upstream record or a whole lookup response, which
+// is the format of the samples under docs/samples.
+func NewDataset(fsys fs.FS) (Correios, error) {
+	const op errors.Op = "correios.NewDataset"
+
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, errors.E(op, errors.KindUnexpected, err)
+	}
+
+	d := &dataset{addresses: make(map[string]*storage.Address, len(files))}
+	for _, name := range files {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, errors.E(op, errors.KindUnexpected, err)
+		}
+
+		cep := onlyDigits(strings.TrimSuffix(path.Base(name), path.Ext(name)))
+		response, err := DecodeLookupResponse(data)
+		if err != nil {
+			return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("failed to decode %s: %v", name, err))
+		}
+
+		if address := response.toAddress(cep); address != nil {
+			d.addresses[cep] = address
+		}
+	}
+
+	return d, nil
+}
+
+func (d *dataset) Check(ctx context.Context) error {
+	return nil
+}
+
+func (d *dataset) Lookup(ctx context.Context, cep string) (*storage.Address, error) {
+	const op errors.Op = "dataset.Lookup"
+
+	address, ok := d.addresses[onlyDigits(cep)]
+	if !ok {
+		return nil, errors.E(op, errors.KindNotFound, fmt.Sprintf("cep %s not found", cep))
+	}
+
+	return copyAddress(address), nil
+}
+
+// Search matches the state and city exactly and the street by fragment,
+// ignoring case.
+func (d *dataset) Search(ctx context.Context, query SearchQuery) ([]*storage.Address, error) {
+	const op errors.Op = "dataset.Search"
+
+	if err := query.Normalize(); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	street := strings.ToLower(query.Street)
+	matches := func(address *storage.Address) bool {
+		return strings.EqualFold(address.State, query.State) &&
+			strings.EqualFold(address.City, query.City) &&
+			strings.Contains(strings.ToLower(address.Location), street)
+	}
+
+	addresses := make([]*storage.Address, 0)
+	for _, address := range d.addresses {
+		records := address.Children
+		if len(records) == 0 {
+			records = []*storage.Address{address}
+		}
+
+		for _, record := range records {
+			if matches(record) {
+				addresses = append(addresses, copyAddress(record))
+			}
+		}
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].CEP < addresses[j].CEP
+	})
+
+	return addresses, nil
+}
+
+// DecodeLookupResponse decodes either a full lookup response or a single
+// record, as found in the samples.
+func DecodeLookupResponse(data []byte) (*LookupResponse, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+
+	response := new(LookupResponse)
+	if _, ok := probe["dados"]; ok {
+		if err := json.Unmarshal(data, response); err != nil {
+			return nil, err
+		}
+
+		return response, nil
+	}
+
+	dado := new(Dado)
+	if err := json.Unmarshal(data, dado); err != nil {
+		return nil, err
+	}
+
+	response.Total = 1
+	response.Dados = []*Dado{dado}
+
+	return response, nil
+}
+
+// copyAddress deep copies address, so callers cannot change the dataset
+// through the addresses it answers.
+func copyAddress(address *storage.Address) *storage.Address {
+	clone := *address
+	if address.NumberRange != nil {
+		numberRange := *address.NumberRange
+		clone.NumberRange = &numberRange
+	}
+
+	if address.POBoxRanges != nil {
+		clone.POBoxRanges = append([]storage.POBoxRange(nil), address.POBoxRanges...)
+	}
+
+	if address.CEPRanges != nil {
+		clone.CEPRanges = append([]storage.CEPRange(nil), address.CEPRanges...)
+	}
+
+	clone.Children = nil
+	for _, child := range address.Children {
+		clone.Children = append(clone.Children, copyAddress(child))
+	}
+
+	return &clone
+}
+
+func onlyDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correios
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"golang.org/x/time/rate"
+)
+
+const DefaultLimiterWait = 5 * time.Second
+
+type LimiterOptions struct {
+	// Rate is the number of lookups per second. Zero means unlimited.
+	Rate float64
+
+	// Burst is the number of lookups allowed at once above Rate. Defaults
+	// to 1.
+	Burst int
+
+	// MaxInFlight is the number of concurrent lookups. Zero means unlimited.
+	MaxInFlight int
+
+	// MaxWait is how long a lookup waits for its turn before failing with
+	// KindRateLimit. Defaults to DefaultLimiterWait.
+	MaxWait time.Duration
+}
+
+// Limiter caps the rate and concurrency of the lookups made to a provider,
+// so bulk warm-ups do not get us blocked upstream.
+type Limiter struct {
+	name     string
+	provider Correios
+	opts     LimiterOptions
+	limiter  *rate.Limiter
+	inFlight chan struct{}
+}
+
+var _ Correios = (*Limiter)(nil)
+
+func NewLimiter(name string, provider Correios, opts LimiterOptions) *Limiter {
+	if opts.Burst < 1 {
+		opts.Burst = 1
+	}
+
+	if opts.MaxWait <= 0 {
+		opts.MaxWait = DefaultLimiterWait
+	}
+
+	l := &Limiter{
+		name:     name,
+		provider: provider,
+		opts:     opts,
+		limiter:  rate.NewLimiter(rate.Inf, 0),
+	}
+
+	if opts.Rate > 0 {
+		l.limiter = rate.NewLimiter(rate.Limit(opts.Rate), opts.Burst)
+	}
+
+	if opts.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, opts.MaxInFlight)
+	}
+
+	return l
+}
+
+// Check probes the provider without being limited.
+func (l *Limiter) Check(ctx context.Context) error {
+	return l.provider.Check(ctx)
+}
+
+func (l *Limiter) Lookup(ctx context.Context, cep string) (*storage.Address, error) {
+	const op errors.Op = "correios.Limiter.Lookup"
+
+	release, err := l.acquire(ctx)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	defer release()
+
+	return l.provider.Lookup(ctx, cep)
+}
+
+func (l *Limiter) Search(ctx context.Context, query SearchQuery) ([]*storage.Address, error) {
+	const op errors.Op = "correios.Limiter.Search"
+
+	release, err := l.acquire(ctx)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	defer release()
+
+	return l.provider.Search(ctx, query)
+}
+
+// acquire waits for a slot and a token, up to MaxWait. The returned function
+// frees the slot.
+func (l *Limiter) acquire(ctx context.Context) (func(), error) {
+	const op errors.Op = "correios.Limiter.acquire"
+
+	waitCtx, cancel := context.WithTimeout(ctx, l.opts.MaxWait)
+	defer cancel()
+
+	release := func() {}
+	if l.inFlight != nil {
+		select {
+		case l.inFlight <- struct{}{}:
+			release = func() { <-l.inFlight }
+		case <-waitCtx.Done():
+			return nil, l.waitError(ctx, op)
+		}
+	}
+
+	// Wait fails right away when the token would only be available after
+	// the deadline.
+	if err := l.limiter.Wait(waitCtx); err != nil {
+		release()
+		return nil, l.waitError(ctx, op)
+	}
+
+	return release, nil
+}
+
+func (l *Limiter) waitError(ctx context.Context, op errors.Op) error {
+	if err := ctx.Err(); err != nil {
+		return errors.E(op, errors.KindUnexpected, err)
+	}
+
+	return errors.E(op, errors.KindRateLimit, fmt.Sprintf("too many lookups to provider %s", l.name))
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correios
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingProvider holds every lookup until release is closed.
+type blockingProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingProvider) Check(ctx context.Context) error {
+	return nil
+}
+
+func (b *blockingProvider) Lookup(ctx context.Context, cep string) (*storage.Address, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return &storage.Address{CEP: cep}, nil
+}
+
+func (b *blockingProvider) Search(ctx context.Context, query SearchQuery) ([]*storage.Address, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return nil, nil
+}
+
+func TestLimiter_Rate(t *testing.T) {
+	ctx := context.Background()
+	provider := &fakeProvider{address: &storage.Address{CEP: "74323240"}}
+	l := NewLimiter("fake", provider, LimiterOptions{Rate: 1, Burst: 2, MaxWait: 10 * time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		_, err := l.Lookup(ctx, "74323240")
+		require.NoError(t, err)
+	}
+
+	_, err := l.Lookup(ctx, "74323240")
+	assert.True(t, errors.Is(err, errors.KindRateLimit), "expected KindRateLimit, got %v", err)
+	assert.Equal(t, 2, provider.calls)
+}
+
+func TestLimiter_MaxInFlight(t *testing.T) {
+	ctx := context.Background()
+	provider := &blockingProvider{started: make(chan struct{}), release: make(chan struct{})}
+	l := NewLimiter("fake", provider, LimiterOptions{MaxInFlight: 1, MaxWait: 10 * time.Millisecond})
+
+	done := make(chan error)
+	go func() {
+		_, err := l.Lookup(ctx, "74323240")
+		done <- err
+	}()
+	<-provider.started
+
+	_, err := l.Lookup(ctx, "74323240")
+	assert.True(t, errors.Is(err, errors.KindRateLimit), "expected KindRateLimit, got %v", err)
+
+	// Waiting callers give up as soon as their context is done.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = l.Lookup(canceled, "74323240")
+	assert.True(t, errors.Is(err, errors.KindUnexpected), "expected KindUnexpected, got %v", err)
+
+	close(provider.release)
+	require.NoError(t, <-done)
+
+	go func() { <-provider.started }()
+	_, err = l.Lookup(ctx, "74323240")
+	require.NoError(t, err)
+}
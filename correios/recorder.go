@@ -0,0 +1,224 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correios
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/pkg/log"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// RecorderMode selects whether a Recorder records or replays lookups.
+type RecorderMode string
+
+const (
+	// RecorderRecord passes lookups through and writes their outcome.
+	RecorderRecord RecorderMode = "record"
+	// RecorderReplay answers lookups from the cassettes only.
+	RecorderReplay RecorderMode = "replay"
+)
+
+// Cassette is the recorded outcome of a lookup, stored as <cep>.json, or of
+// a search, stored as search-<hash of the query>.json.
+type Cassette struct {
+	CEP        string             `json:"cep,omitempty"`
+	Query      *SearchQuery       `json:"query,omitempty"`
+	RecordedAt time.Time          `json:"recorded_at"`
+	Address    *storage.Address   `json:"address,omitempty"`
+	Addresses  []*storage.Address `json:"addresses,omitempty"`
+	Error      *CassetteError     `json:"error,omitempty"`
+}
+
+// CassetteError is a recorded lookup failure.
+type CassetteError struct {
+	Kind    int    `json:"kind"`
+	Message string `json:"message"`
+}
+
+// Recorder records the lookups of a provider to a directory of JSON
+// cassettes, one per CEP, and replays them deterministically, so addresses
+// can be reproduced exactly as upstream returned them.
+type Recorder struct {
+	provider Correios
+	dir      string
+	mode     RecorderMode
+	now      func() time.Time
+	logger   logrus.FieldLogger
+
+	mu sync.Mutex
+}
+
+var _ Correios = (*Recorder)(nil)
+
+// NewRecorder returns a Recorder keeping its cassettes in dir. The provider
+// is not used when replaying.
+func NewRecorder(provider Correios, dir string, mode RecorderMode) (*Recorder, error) {
+	const op errors.Op = "correios.NewRecorder"
+
+	switch mode {
+	case RecorderRecord:
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, errors.E(op, errors.KindUnexpected, err)
+		}
+	case RecorderReplay:
+		if _, err := os.Stat(dir); err != nil {
+			return nil, errors.E(op, errors.KindBadRequest, err)
+		}
+	default:
+		return nil, errors.E(op, errors.KindBadRequest, fmt.Sprintf("unknown recorder mode %q", mode))
+	}
+
+	return &Recorder{
+		provider: provider,
+		dir:      dir,
+		mode:     mode,
+		now:      time.Now,
+		logger:   log.WithField("component", "correios.recorder"),
+	}, nil
+}
+
+func (r *Recorder) Check(ctx context.Context) error {
+	if r.mode == RecorderReplay {
+		return nil
+	}
+
+	return r.provider.Check(ctx)
+}
+
+func (r *Recorder) Lookup(ctx context.Context, cep string) (*storage.Address, error) {
+	if r.mode == RecorderReplay {
+		cassette, err := r.replay(r.path(cep))
+		if err != nil {
+			return nil, err
+		}
+
+		return cassette.Address, nil
+	}
+
+	address, err := r.provider.Lookup(ctx, cep)
+	r.record(ctx, r.path(cep), &Cassette{CEP: cep, Address: address}, err)
+
+	return address, err
+}
+
+func (r *Recorder) Search(ctx context.Context, query SearchQuery) ([]*storage.Address, error) {
+	if r.mode == RecorderReplay {
+		cassette, err := r.replay(r.searchPath(query))
+		if err != nil {
+			return nil, err
+		}
+
+		return cassette.Addresses, nil
+	}
+
+	addresses, err := r.provider.Search(ctx, query)
+	r.record(ctx, r.searchPath(query), &Cassette{Query: &query, Addresses: addresses}, err)
+
+	return addresses, err
+}
+
+// record writes a cassette. Failing to do so does not fail the lookup, and
+// lookups abandoned by the caller say nothing about upstream, so they are
+// not recorded.
+func (r *Recorder) record(ctx context.Context, path string, cassette *Cassette, lookupErr error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err := r.write(path, cassette, lookupErr); err != nil {
+		r.logger.WithError(err).WithField("cassette", path).Warn("unable to record lookup")
+	}
+}
+
+func (r *Recorder) write(path string, cassette *Cassette, lookupErr error) error {
+	const op errors.Op = "correios.Recorder.write"
+
+	cassette.RecordedAt = r.now().UTC()
+	if lookupErr != nil {
+		cassette.Error = &CassetteError{
+			Kind:    errors.Kind(lookupErr),
+			Message: lookupErr.Error(),
+		}
+	}
+
+	data, err := json.MarshalIndent(cassette, "", "  ")
+	if err != nil {
+		return errors.E(op, errors.KindUnexpected, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Write then rename, so replays never read a partial cassette.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return errors.E(op, errors.KindUnexpected, err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.E(op, errors.KindUnexpected, err)
+	}
+
+	return nil
+}
+
+// replay reads a cassette, returning the error it recorded, if any.
+func (r *Recorder) replay(path string) (*Cassette, error) {
+	const op errors.Op = "correios.Recorder.replay"
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("no cassette %s", filepath.Base(path)))
+	}
+
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("invalid cassette %s, %v", filepath.Base(path), err))
+	}
+
+	if cassette.Error != nil {
+		return nil, errors.E(op, cassette.Error.Kind, cassette.Error.Message)
+	}
+
+	return &cassette, nil
+}
+
+func (r *Recorder) path(cep string) string {
+	return filepath.Join(r.dir, onlyDigits(cep)+".json")
+}
+
+// searchPath names search cassettes after a hash of the query, so that any
+// city or street name makes a valid file name. Queries differing only in
+// case, accents or spacing share a cassette.
+func (r *Recorder) searchPath(query SearchQuery) string {
+	fold := func(s string) string {
+		return strings.Join(strings.Fields(storage.Fold(s)), " ")
+	}
+
+	query = SearchQuery{State: fold(query.State), City: fold(query.City), Street: fold(query.Street)}
+	sum := sha256.Sum256([]byte(query.String()))
+	return filepath.Join(r.dir, fmt.Sprintf("search-%x.json", sum[:8]))
+}
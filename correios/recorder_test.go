@@ -0,0 +1,106 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correios
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "cassettes")
+
+	provider := &fakeProvider{address: &storage.Address{
+		CEP:          "01003900",
+		State:        "SP",
+		Unit:         "Edifício Triângulo",
+		Type:         storage.CEPTypeBigUser,
+		NumberRange:  &storage.NumberRange{Side: storage.SideOdd, From: 1},
+		CEPRanges:    []storage.CEPRange{{Start: "01000000", End: "01099999"}},
+		Neighborhood: "Sé",
+	}}
+	recorder, err := NewRecorder(provider, dir, RecorderRecord)
+	require.NoError(t, err)
+
+	recorded, err := recorder.Lookup(ctx, "01003900")
+	require.NoError(t, err)
+
+	provider.address = nil
+	provider.err = errors.E("fake", errors.KindNotFound, "cep 01003901 not found")
+	_, err = recorder.Lookup(ctx, "01003901")
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+	assert.FileExists(t, filepath.Join(dir, "01003900.json"))
+	assert.FileExists(t, filepath.Join(dir, "01003901.json"))
+
+	// Replays never reach the provider.
+	provider.err = errors.E("fake", errors.KindUnexpected, "down")
+	replayer, err := NewRecorder(provider, dir, RecorderReplay)
+	require.NoError(t, err)
+	require.NoError(t, replayer.Check(ctx))
+
+	replayed, err := replayer.Lookup(ctx, "01003-900")
+	require.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+
+	_, err = replayer.Lookup(ctx, "01003901")
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+	assert.EqualError(t, err, "cep 01003901 not found")
+
+	_, err = replayer.Lookup(ctx, "74323240")
+	assert.True(t, errors.Is(err, errors.KindUnexpected), "expected KindUnexpected, got %v", err)
+	assert.Equal(t, 2, provider.calls)
+}
+
+func TestRecorder_Search(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	query := SearchQuery{State: "GO", City: "Goiânia", Street: "Couto de Magalhães"}
+
+	provider := &fakeProvider{address: &storage.Address{CEP: "74323240", State: "GO", City: "Goiânia"}}
+	recorder, err := NewRecorder(provider, dir, RecorderRecord)
+	require.NoError(t, err)
+
+	recorded, err := recorder.Search(ctx, query)
+	require.NoError(t, err)
+
+	replayer, err := NewRecorder(provider, dir, RecorderReplay)
+	require.NoError(t, err)
+
+	query.City = "GOIANIA"
+	query.Street = " couto  de magalhaes "
+	replayed, err := replayer.Search(ctx, query)
+	require.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+
+	query.Street = "Anhanguera"
+	_, err = replayer.Search(ctx, query)
+	assert.True(t, errors.Is(err, errors.KindUnexpected), "expected KindUnexpected, got %v", err)
+	assert.Equal(t, 1, provider.calls)
+}
+
+func TestNewRecorder_Invalid(t *testing.T) {
+	_, err := NewRecorder(nil, t.TempDir(), "rewind")
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+
+	_, err = NewRecorder(nil, filepath.Join(t.TempDir(), "missing"), RecorderReplay)
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correios
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+)
+
+// Factory creates a provider registered under a name.
+type Factory func() (Correios, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		"correios":  func() (Correios, error) { return New(Options{}), nil },
+		"viacep":    func() (Correios, error) { return NewViaCEP(), nil },
+		"brasilapi": func() (Correios, error) { return NewBrasilAPI(), nil },
+	}
+)
+
+// Register makes a provider available by name, replacing any provider
+// previously registered with the same name.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[name] = factory
+}
+
+// Providers returns the sorted names of the registered providers.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// NewProvider creates the named providers and chains them in the given order.
+func NewProvider(names ...string) (Correios, error) {
+	const op errors.Op = "correios.NewProvider"
+
+	if len(names) == 0 {
+		return nil, errors.E(op, errors.KindBadRequest, "at least one provider is required")
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	providers := make([]Correios, 0, len(names))
+	for _, name := range names {
+		factory, ok := registry[name]
+		if !ok {
+			return nil, errors.E(op, errors.KindBadRequest, fmt.Sprintf("unknown provider %q", name))
+		}
+
+		provider, err := factory()
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return NewChain(providers...), nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correios
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+)
+
+// MinSearchStreet is the shortest street fragment upstream searches accept.
+const MinSearchStreet = 3
+
+// SearchQuery finds the CEPs of a street, given its state, city and a
+// fragment of its name.
+type SearchQuery struct {
+	State  string `json:"state"`
+	City   string `json:"city"`
+	Street string `json:"street"`
+}
+
+// Normalize trims the query and upper-cases its state, then validates it.
+func (q *SearchQuery) Normalize() error {
+	const op errors.Op = "correios.SearchQuery.Normalize"
+
+	q.State = strings.ToUpper(strings.TrimSpace(q.State))
+	q.City = strings.TrimSpace(q.City)
+	q.Street = strings.TrimSpace(q.Street)
+
+	if len(q.State) != 2 || strings.IndexFunc(q.State, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0 {
+		return errors.E(op, errors.KindBadRequest, fmt.Sprintf("invalid state %q, must have 2 letters", q.State))
+	}
+
+	if q.City == "" {
+		return errors.E(op, errors.KindBadRequest, "city is required")
+	}
+
+	if utf8.RuneCountInString(q.Street) < MinSearchStreet {
+		return errors.E(op, errors.KindBadRequest, fmt.Sprintf("street must have at least %d characters", MinSearchStreet))
+	}
+
+	return nil
+}
+
+func (q SearchQuery) String() string {
+	return fmt.Sprintf("%s/%s/%s", q.State, q.City, q.Street)
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package correios
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/pkg/log"
+	"github.com/insighted4/correios-cep/pkg/net"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/insighted4/correios-cep/street"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	viaCEPBaseURL  = "https://viacep.com.br"
+	viaCEPCheckURL = "/ws/01001000/json/"
+)
+
+type viaCEP struct {
+	cli    *resty.Client
+	logger logrus.FieldLogger
+}
+
+var _ Correios = (*viaCEP)(nil)
+
+// NewViaCEP returns a provider backed by the ViaCEP public API.
+func NewViaCEP() Correios {
+	logger := log.WithField("component", "viacep")
+	cli := net.NewClient().
+		SetBaseURL(viaCEPBaseURL).
+		SetHeader("Accept", "application/json").
+		SetLogger(logger).
+		SetRetryCount(3).
+		SetTimeout(20 * time.Second)
+
+	return &viaCEP{
+		cli:    cli,
+		logger: logger,
+	}
+}
+
+func (v *viaCEP) Check(ctx context.Context) error {
+	const op errors.Op = "viacep.Check"
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	resp, err := v.cli.R().SetContext(ctx).Get(viaCEPCheckURL)
+	if err != nil {
+		v.logger.Errorf("failed to check ViaCEP: %v", err)
+		return errors.E(op, errors.KindUnexpected, err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		v.logger.Errorf("failed to check ViaCEP: unexpected status code %d", resp.StatusCode())
+		return errors.E(op, errors.KindUnexpected, fmt.Sprintf("GET %s returned %d", viaCEPCheckURL, resp.StatusCode()))
+	}
+
+	return nil
+}
+
+type viaCEPResponse struct {
+	CEP         string      `json:"cep"`
+	Logradouro  string      `json:"logradouro"`
+	Complemento string      `json:"complemento"`
+	Bairro      string      `json:"bairro"`
+	Localidade  string      `json:"localidade"`
+	UF          string      `json:"uf"`
+	Erro        interface{} `json:"erro"`
+}
+
+func (r *viaCEPResponse) notFound() bool {
+	return r.Erro == true || r.Erro == "true"
+}
+
+func (r *viaCEPResponse) toAddress() *storage.Address {
+	address := &storage.Address{
+		CEP:          onlyDigits(r.CEP),
+		State:        r.UF,
+		City:         r.Localidade,
+		Neighborhood: r.Bairro,
+		Location:     r.Logradouro,
+		Complement:   r.Complemento,
+	}
+
+	// ViaCEP moves the range of numbers into the complement.
+	location := r.Logradouro
+	if r.Complemento != "" {
+		location += " - " + r.Complemento
+	}
+	address.Street, address.NumberRange = street.Parse(location)
+	if address.NumberRange == nil {
+		address.Street = r.Logradouro
+	}
+
+	return address
+}
+
+func (v *viaCEP) Lookup(ctx context.Context, cep string) (*storage.Address, error) {
+	const op errors.Op = "viacep.Lookup"
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	path := fmt.Sprintf("/ws/%s/json/", cep)
+	response := new(viaCEPResponse)
+	resp, err := v.cli.R().SetContext(ctx).SetResult(response).Get(path)
+	if err != nil {
+		v.logger.Errorf("failed to lookup address: %v", err)
+		return nil, errors.E(op, errors.KindUnexpected, err)
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusOK:
+	case http.StatusBadRequest:
+		return nil, errors.E(op, errors.KindBadRequest, fmt.Sprintf("invalid cep %s", cep))
+	default:
+		v.logger.Errorf("failed to lookup address: unexpected status code %d", resp.StatusCode())
+		return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("GET %s returned %d", path, resp.StatusCode()))
+	}
+
+	if response.notFound() {
+		return nil, errors.E(op, errors.KindNotFound, fmt.Sprintf("cep %s not found", cep))
+	}
+
+	return response.toAddress(), nil
+}
+
+func (v *viaCEP) Search(ctx context.Context, query SearchQuery) ([]*storage.Address, error) {
+	const op errors.Op = "viacep.Search"
+
+	if err := query.Normalize(); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	path := fmt.Sprintf("/ws/%s/%s/%s/json/", url.PathEscape(query.State), url.PathEscape(query.City), url.PathEscape(query.Street))
+	var response []*viaCEPResponse
+	resp, err := v.cli.R().SetContext(ctx).SetResult(&response).Get(path)
+	if err != nil {
+		v.logger.Errorf("failed to search addresses: %v", err)
+		return nil, errors.E(op, errors.KindUnexpected, err)
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusOK:
+	case http.StatusBadRequest:
+		return nil, errors.E(op, errors.KindBadRequest, fmt.Sprintf("invalid search %s", query))
+	default:
+		v.logger.Errorf("failed to search addresses: unexpected status code %d", resp.StatusCode())
+		return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("GET %s returned %d", path, resp.StatusCode()))
+	}
+
+	addresses := make([]*storage.Address, 0, len(response))
+	for _, r := range response {
+		addresses = append(addresses, r.toAddress())
+	}
+
+	return addresses, nil
+}
@@ -0,0 +1,339 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dne
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+)
+
+// Files of an eDNE delta. They hold the records changed since the previous
+// release, in the layout of the full files, followed by the operation.
+const (
+	FileDeltaLocality     = "DELTA_LOG_LOCALIDADE.TXT"
+	FileDeltaNeighborhood = "DELTA_LOG_BAIRRO.TXT"
+	FileDeltaStreet       = "DELTA_LOG_LOGRADOURO.TXT"
+	FileDeltaBigUser      = "DELTA_LOG_GRANDE_USUARIO.TXT"
+	FileDeltaUnit         = "DELTA_LOG_UNID_OPER.TXT"
+	FileDeltaCommunityBox = "DELTA_LOG_CPC.TXT"
+)
+
+// Operation is the change a delta record makes.
+type Operation string
+
+const (
+	OpInsert Operation = "INS"
+	OpUpdate Operation = "UPD"
+	OpDelete Operation = "DEL"
+)
+
+// Change is an operation on the address of a CEP. Deletions only carry the
+// CEP.
+type Change struct {
+	Op      Operation
+	Address *storage.Address
+}
+
+// Delta is a directory of eDNE delta files.
+type Delta struct {
+	files files
+}
+
+// OpenDelta opens the eDNE delta in fsys, which must hold at least one of
+// the delta files.
+func OpenDelta(fsys fs.FS) (*Delta, error) {
+	const op errors.Op = "dne.OpenDelta"
+
+	f, err := openFiles(fsys)
+	if err != nil {
+		return nil, errors.E(op, errors.KindBadRequest, err)
+	}
+
+	for _, name := range []string{
+		FileDeltaLocality,
+		FileDeltaNeighborhood,
+		FileDeltaStreet,
+		FileDeltaBigUser,
+		FileDeltaUnit,
+		FileDeltaCommunityBox,
+	} {
+		if f.has(name) {
+			return &Delta{files: f}, nil
+		}
+	}
+
+	return nil, errors.E(op, errors.KindBadRequest, "no DELTA_LOG_*.TXT files found")
+}
+
+// WalkDelta applies the locality and neighborhood changes of d to r, then
+// calls fn with every address change of d. It stops at the first error,
+// returning it.
+//
+// Deltas do not list the addresses of a renamed locality or neighborhood
+// again, so those are read from the full eDNE of r and updated before the
+// address changes of d, which take precedence. Nor do they list the CEP a
+// record had before an update, which is read from the full eDNE as well and
+// deleted when the CEP changed.
+func (r *Reader) WalkDelta(ctx context.Context, d *Delta, fn func(change Change) error) error {
+	const op errors.Op = "dne.Reader.WalkDelta"
+
+	visit := func(operation Operation, address *storage.Address) error {
+		if err := ctx.Err(); err != nil {
+			return errors.E(op, errors.KindUnexpected, err)
+		}
+
+		if operation == OpDelete {
+			address = &storage.Address{CEP: address.CEP}
+		}
+
+		return fn(Change{Op: operation, Address: address})
+	}
+
+	renamedLocalities := make(map[string]bool)
+	renamedNeighborhoods := make(map[string]bool)
+
+	err := readDelta(d.files, FileDeltaLocality, 7, func(operation Operation, fields []string) error {
+		// A locality losing its CEP, or changing it, leaves the old one.
+		if old := r.Locality(fields[0]); old != nil && (operation == OpDelete || old.CEP != fields[3]) {
+			if err := visit(OpDelete, old); err != nil {
+				return err
+			}
+		}
+
+		if operation == OpDelete {
+			delete(r.localities, fields[0])
+			return nil
+		}
+
+		if old, ok := r.localities[fields[0]]; ok && (old.name != fields[2] || old.parent != fields[6]) {
+			renamedLocalities[fields[0]] = true
+		}
+
+		r.setLocality(fields)
+		if address := r.Locality(fields[0]); address != nil {
+			return visit(operation, address)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = readDelta(d.files, FileDeltaNeighborhood, 4, func(operation Operation, fields []string) error {
+		if operation == OpDelete {
+			delete(r.neighborhoods, fields[0])
+			return nil
+		}
+
+		if old, ok := r.neighborhoods[fields[0]]; ok && old != fields[3] {
+			renamedNeighborhoods[fields[0]] = true
+		}
+
+		r.setNeighborhood(fields)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	kinds := r.recordKinds()
+	if err := r.walkRenamed(kinds, renamedLocalities, renamedNeighborhoods, visit); err != nil {
+		return err
+	}
+
+	changed := make([]*deltaRecords, len(kinds))
+	for i, kind := range kinds {
+		if changed[i], err = r.readDeltaRecords(d, kind); err != nil {
+			return err
+		}
+	}
+
+	// Every stale CEP is deleted before any record is written, so records
+	// swapping their CEPs keep both.
+	for _, records := range changed {
+		for _, key := range records.keys {
+			for _, cep := range records.byKey[key].stale() {
+				if err := visit(OpDelete, &storage.Address{CEP: cep}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for i, kind := range kinds {
+		err := readDelta(d.files, kind.delta, kind.minFields, func(operation Operation, fields []string) error {
+			// Only the last change of a record is written.
+			record := changed[i].byKey[fields[0]]
+			if record.changes--; record.changes > 0 {
+				return nil
+			}
+
+			return visit(operation, kind.convert(fields))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordKind is a kind of address record of the eDNE.
+type recordKind struct {
+	delta           string
+	base            []string
+	minFields       int
+	cepField        int
+	hasNeighborhood bool
+	convert         func(fields []string) *storage.Address
+}
+
+func (r *Reader) recordKinds() []recordKind {
+	return []recordKind{
+		{FileDeltaStreet, r.streetFiles(), 9, 7, true, r.Street},
+		{FileDeltaBigUser, []string{FileBigUser}, 8, 7, true, r.BigUser},
+		{FileDeltaUnit, []string{FileUnit}, 8, 7, true, r.Unit},
+		{FileDeltaCommunityBox, []string{FileCommunityBox}, 6, 5, false, r.CommunityBox},
+	}
+}
+
+// deltaRecord is the history of a record changed by a delta.
+type deltaRecord struct {
+	// base is the CEP of the record in the full eDNE, if any.
+	base string
+	// ceps are the CEPs of the record after each of its changes.
+	ceps    []string
+	changes int
+}
+
+// stale returns the CEPs the record had before its last change, other than
+// the one it is left with.
+func (d *deltaRecord) stale() []string {
+	last := d.ceps[len(d.ceps)-1]
+
+	var stale []string
+	for _, cep := range append([]string{d.base}, d.ceps[:len(d.ceps)-1]...) {
+		if cep != "" && cep != last && !slices.Contains(stale, cep) {
+			stale = append(stale, cep)
+		}
+	}
+
+	return stale
+}
+
+// deltaRecords are the records of a kind changed by a delta, by key.
+type deltaRecords struct {
+	keys  []string
+	byKey map[string]*deltaRecord
+}
+
+// readDeltaRecords reads the records of kind changed by d, along with their
+// CEPs in the full eDNE of r.
+func (r *Reader) readDeltaRecords(d *Delta, kind recordKind) (*deltaRecords, error) {
+	records := &deltaRecords{byKey: make(map[string]*deltaRecord)}
+
+	err := readDelta(d.files, kind.delta, kind.minFields, func(operation Operation, fields []string) error {
+		record, ok := records.byKey[fields[0]]
+		if !ok {
+			record = &deltaRecord{}
+			records.byKey[fields[0]] = record
+			records.keys = append(records.keys, fields[0])
+		}
+
+		record.ceps = append(record.ceps, fields[kind.cepField])
+		record.changes++
+		return nil
+	})
+	if err != nil || len(records.keys) == 0 {
+		return records, err
+	}
+
+	for _, name := range kind.base {
+		err := r.files.read(name, kind.minFields, func(fields []string) error {
+			if record, ok := records.byKey[fields[0]]; ok {
+				record.base = fields[kind.cepField]
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// walkRenamed calls visit with an update of every address of the full eDNE
+// in the renamed localities or neighborhoods. Districts and villages of a
+// renamed city are named after it, so they are renamed too.
+func (r *Reader) walkRenamed(kinds []recordKind, localities, neighborhoods map[string]bool, visit func(operation Operation, address *storage.Address) error) error {
+	if len(localities) == 0 && len(neighborhoods) == 0 {
+		return nil
+	}
+
+	for _, key := range r.localityKeys() {
+		if !localities[r.localities[key].parent] {
+			continue
+		}
+
+		localities[key] = true
+		if address := r.Locality(key); address != nil {
+			if err := visit(OpUpdate, address); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, kind := range kinds {
+		for _, name := range kind.base {
+			err := r.files.read(name, kind.minFields, func(fields []string) error {
+				if !localities[fields[2]] && !(kind.hasNeighborhood && neighborhoods[fields[3]]) {
+					return nil
+				}
+				return visit(OpUpdate, kind.convert(fields))
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readDelta reads the records of a delta file, splitting the operation off
+// their last field.
+func readDelta(f files, name string, minFields int, fn func(operation Operation, fields []string) error) error {
+	const op errors.Op = "dne.readDelta"
+
+	return f.read(name, minFields+1, func(fields []string) error {
+		last := len(fields) - 1
+		operation := Operation(strings.ToUpper(fields[last]))
+		switch operation {
+		case OpInsert, OpUpdate, OpDelete:
+		default:
+			return errors.E(op, errors.KindBadRequest, fmt.Sprintf("%s has unknown operation %q", name, fields[last]))
+		}
+
+		return fn(operation, fields[:last])
+	})
+}
@@ -0,0 +1,186 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dne
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDeltaFS(t *testing.T) fstest.MapFS {
+	return fstest.MapFS{
+		"DELTA_LOG_LOCALIDADE.TXT": latin1(t, "2@GO@Anápolis@75000001@0@M@@ANAPOLIS@5201108@UPD"),
+		"DELTA_LOG_BAIRRO.TXT":     latin1(t, "11@GO@1@Setor Central@St Central@INS"),
+		"DELTA_LOG_LOGRADOURO.TXT": latin1(t,
+			"100@GO@1@10@@General Couto de Magalhães@- até 999/1000@74323240@Avenida@S@Av Gen C Magalhaes@UPD",
+			"101@GO@1@10@@Anhanguera@@74323250@Avenida@N@Anhanguera@DEL",
+			"102@GO@1@11@@Goiás@@74001970@Avenida@S@Av Goias@INS",
+		),
+		"DELTA_LOG_CPC.TXT": latin1(t, "400@GO@3@CPC Interlândia@Rua 1, 10@75149970@DEL"),
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New(nil)
+
+	r, err := Open(newTestFS(t))
+	require.NoError(t, err)
+
+	_, err = Import(ctx, r, s)
+	require.NoError(t, err)
+
+	d, err := OpenDelta(newTestDeltaFS(t))
+	require.NoError(t, err)
+
+	stats, err := ApplyDelta(ctx, r, d, s, "2310")
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Created: 2, Updated: 1, Deleted: 3}, stats)
+
+	address, err := s.GetAddress(ctx, "74001970")
+	require.NoError(t, err)
+	assert.Equal(t, "Setor Central", address.Neighborhood)
+	assert.Equal(t, "Avenida Goiás", address.Location)
+
+	locality, err := s.GetAddress(ctx, "75000001")
+	require.NoError(t, err)
+	assert.Equal(t, "Anápolis", locality.City)
+
+	for _, cep := range []string{"75000000", "74323250", "75149970"} {
+		_, err := s.GetAddress(ctx, cep)
+		assert.True(t, errors.Is(err, errors.KindNotFound), "expected %s to be deleted, got %v", cep, err)
+	}
+
+	_, err = ApplyDelta(ctx, r, d, s, "2310")
+	assert.True(t, errors.Is(err, errors.KindAlreadyExists), "expected KindAlreadyExists, got %v", err)
+}
+
+func TestApplyDelta_Rollback(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New(nil)
+
+	r, err := Open(newTestFS(t))
+	require.NoError(t, err)
+
+	_, err = Import(ctx, r, s)
+	require.NoError(t, err)
+
+	fsys := newTestDeltaFS(t)
+	fsys["DELTA_LOG_CPC.TXT"] = latin1(t, "400@GO@3@CPC Interlândia@Rua 1, 10@75149970@REN")
+
+	d, err := OpenDelta(fsys)
+	require.NoError(t, err)
+
+	_, err = ApplyDelta(ctx, r, d, s, "2310")
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+
+	_, err = s.GetAddress(ctx, "74323250")
+	assert.NoError(t, err, "expected the delta to be rolled back")
+
+	_, err = s.GetDeltaVersion(ctx, "2310")
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+}
+
+func TestApplyDelta_Rename(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New(nil)
+
+	r, err := Open(newTestFS(t))
+	require.NoError(t, err)
+
+	_, err = Import(ctx, r, s)
+	require.NoError(t, err)
+
+	d, err := OpenDelta(fstest.MapFS{
+		"DELTA_LOG_LOCALIDADE.TXT": latin1(t, "2@GO@Anápolis Velha@75000000@0@M@@ANAPOLIS VELHA@5201108@UPD"),
+		"DELTA_LOG_BAIRRO.TXT":     latin1(t, "10@GO@1@Vila Mauá Nova@V Maua Nova@UPD"),
+	})
+	require.NoError(t, err)
+
+	stats, err := ApplyDelta(ctx, r, d, s, "2311")
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Updated: 7}, stats)
+
+	for _, cep := range []string{"74323240", "74323250", "74323999", "74323970"} {
+		address, err := s.GetAddress(ctx, cep)
+		require.NoError(t, err)
+		assert.Equal(t, "Vila Mauá Nova", address.Neighborhood, cep)
+	}
+
+	for _, cep := range []string{"75000000", "75149000", "75149970"} {
+		address, err := s.GetAddress(ctx, cep)
+		require.NoError(t, err)
+		assert.Equal(t, "Anápolis Velha", address.City, cep)
+	}
+}
+
+func TestApplyDelta_CEPChange(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New(nil)
+
+	r, err := Open(newTestFS(t))
+	require.NoError(t, err)
+
+	_, err = Import(ctx, r, s)
+	require.NoError(t, err)
+
+	d, err := OpenDelta(fstest.MapFS{
+		"DELTA_LOG_LOGRADOURO.TXT": latin1(t,
+			"100@GO@1@10@@General Couto de Magalhães@- até 999/1000@74323250@Avenida@S@Av Gen C Magalhaes@UPD",
+			"101@GO@1@10@@Anhanguera@@74323240@Avenida@N@Anhanguera@UPD",
+		),
+		"DELTA_LOG_UNID_OPER.TXT": latin1(t, "300@GO@1@10@100@AC Vila Mauá@Avenida General Couto de Magalhães, 200@74323971@S@AC V Maua@UPD"),
+		"DELTA_LOG_CPC.TXT": latin1(t,
+			"400@GO@3@CPC Interlândia@Rua 1, 10@75149971@UPD",
+			"400@GO@3@CPC Interlândia@Rua 1, 10@75149972@UPD",
+		),
+	})
+	require.NoError(t, err)
+
+	stats, err := ApplyDelta(ctx, r, d, s, "2312")
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Created: 4, Deleted: 4}, stats)
+
+	// Streets swapping their CEPs keep both.
+	for cep, location := range map[string]string{
+		"74323240": "Anhanguera",
+		"74323250": "Avenida General Couto de Magalhães - até 999/1000",
+	} {
+		address, err := s.GetAddress(ctx, cep)
+		require.NoError(t, err)
+		assert.Equal(t, location, address.Location, cep)
+	}
+
+	for _, cep := range []string{"74323971", "75149972"} {
+		_, err := s.GetAddress(ctx, cep)
+		assert.NoError(t, err, cep)
+	}
+
+	for _, cep := range []string{"74323970", "75149970", "75149971"} {
+		_, err := s.GetAddress(ctx, cep)
+		assert.True(t, errors.Is(err, errors.KindNotFound), "expected %s to be deleted, got %v", cep, err)
+	}
+}
+
+func TestOpenDelta_Empty(t *testing.T) {
+	_, err := OpenDelta(fstest.MapFS{"README.TXT": &fstest.MapFile{}})
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+}
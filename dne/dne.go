@@ -0,0 +1,348 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dne reads the eDNE (Diretório Nacional de Endereços), the address
+// database Correios distributes as text files of '@' delimited records.
+//
+// Localities and neighborhoods are loaded in memory to resolve the keys the
+// other files refer to, while streets, big users, postal units and
+// community PO boxes are streamed, since they hold about a million records.
+package dne
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"iter"
+	"sort"
+	"strings"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/insighted4/correios-cep/street"
+)
+
+// Separator delimits the fields of eDNE records.
+const Separator = "@"
+
+// Files of the eDNE. Streets are split by state, in files named
+// LOG_LOGRADOURO_<UF>.TXT.
+const (
+	FileLocality       = "LOG_LOCALIDADE.TXT"
+	FileLocalityRanges = "LOG_FAIXA_LOCALIDADE.TXT"
+	FileNeighborhood   = "LOG_BAIRRO.TXT"
+	FileStreetPrefix   = "LOG_LOGRADOURO_"
+	FileBigUser        = "LOG_GRANDE_USUARIO.TXT"
+	FileUnit           = "LOG_UNID_OPER.TXT"
+	FileUnitRanges     = "LOG_FAIXA_UOP.TXT"
+	FileCommunityBox   = "LOG_CPC.TXT"
+)
+
+// Locality types of LOC_IN_TIPO_LOC.
+const (
+	localityDistrict = "D"
+	localityVillage  = "P"
+)
+
+type locality struct {
+	state  string
+	name   string
+	cep    string
+	kind   string
+	parent string
+}
+
+// Reader resolves the records of an eDNE directory into addresses.
+type Reader struct {
+	files files
+
+	localities    map[string]*locality
+	neighborhoods map[string]string
+	cepRanges     map[string][]storage.CEPRange
+	poBoxRanges   map[string][]storage.POBoxRange
+}
+
+// Open loads the localities and neighborhoods of the eDNE in fsys. Only
+// LOG_LOCALIDADE.TXT and LOG_BAIRRO.TXT are required.
+func Open(fsys fs.FS) (*Reader, error) {
+	const op errors.Op = "dne.Open"
+
+	f, err := openFiles(fsys)
+	if err != nil {
+		return nil, errors.E(op, errors.KindBadRequest, err)
+	}
+
+	r := &Reader{
+		files:         f,
+		localities:    make(map[string]*locality),
+		neighborhoods: make(map[string]string),
+		cepRanges:     make(map[string][]storage.CEPRange),
+		poBoxRanges:   make(map[string][]storage.POBoxRange),
+	}
+
+	for _, name := range []string{FileLocality, FileNeighborhood} {
+		if !f.has(name) {
+			return nil, errors.E(op, errors.KindBadRequest, fmt.Sprintf("missing %s", name))
+		}
+	}
+
+	if err := r.load(); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return r, nil
+}
+
+func (r *Reader) load() error {
+	err := r.files.read(FileLocality, 7, func(fields []string) error {
+		r.setLocality(fields)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = r.files.read(FileNeighborhood, 4, func(fields []string) error {
+		r.setNeighborhood(fields)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = r.files.read(FileLocalityRanges, 3, func(fields []string) error {
+		r.cepRanges[fields[0]] = append(r.cepRanges[fields[0]], storage.CEPRange{Start: fields[1], End: fields[2]})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.files.read(FileUnitRanges, 3, func(fields []string) error {
+		r.poBoxRanges[fields[0]] = append(r.poBoxRanges[fields[0]], storage.POBoxRange{Start: fields[1], End: fields[2]})
+		return nil
+	})
+}
+
+// setLocality stores a LOG_LOCALIDADE record:
+// LOC_NU@UFE_SG@LOC_NO@CEP@LOC_IN_SIT@LOC_IN_TIPO_LOC@LOC_NU_SUB.
+func (r *Reader) setLocality(fields []string) {
+	r.localities[fields[0]] = &locality{
+		state:  fields[1],
+		name:   fields[2],
+		cep:    fields[3],
+		kind:   fields[5],
+		parent: fields[6],
+	}
+}
+
+// setNeighborhood stores a LOG_BAIRRO record: BAI_NU@UFE_SG@LOC_NU@BAI_NO.
+func (r *Reader) setNeighborhood(fields []string) {
+	r.neighborhoods[fields[0]] = fields[3]
+}
+
+// Walk calls fn with every address of the eDNE: localities with a single
+// CEP, then streets, big users, postal units and community PO boxes. It
+// stops at the first error, returning it.
+func (r *Reader) Walk(ctx context.Context, fn func(address *storage.Address) error) error {
+	const op errors.Op = "dne.Reader.Walk"
+
+	visit := func(address *storage.Address) error {
+		if err := ctx.Err(); err != nil {
+			return errors.E(op, errors.KindUnexpected, err)
+		}
+
+		return fn(address)
+	}
+
+	for _, key := range r.localityKeys() {
+		if address := r.Locality(key); address != nil {
+			if err := visit(address); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range r.streetFiles() {
+		if err := r.files.read(name, 9, func(fields []string) error { return visit(r.Street(fields)) }); err != nil {
+			return err
+		}
+	}
+
+	if err := r.files.read(FileBigUser, 8, func(fields []string) error { return visit(r.BigUser(fields)) }); err != nil {
+		return err
+	}
+
+	if err := r.files.read(FileUnit, 8, func(fields []string) error { return visit(r.Unit(fields)) }); err != nil {
+		return err
+	}
+
+	return r.files.read(FileCommunityBox, 6, func(fields []string) error { return visit(r.CommunityBox(fields)) })
+}
+
+// errStop stops a walk whose addresses are no longer wanted.
+var errStop = errors.E("dne.Reader.All", errors.KindUnexpected, "stopped")
+
+// All returns the addresses of Walk as an iterator, ending with the error
+// that stopped the walk, if any.
+func (r *Reader) All(ctx context.Context) iter.Seq2[*storage.Address, error] {
+	return func(yield func(*storage.Address, error) bool) {
+		err := r.Walk(ctx, func(address *storage.Address) error {
+			if !yield(address, nil) {
+				return errStop
+			}
+			return nil
+		})
+		if err != nil && err != errStop {
+			yield(nil, err)
+		}
+	}
+}
+
+// Locality returns the address of the locality with the given key, or nil
+// when its streets have CEPs of their own.
+func (r *Reader) Locality(key string) *storage.Address {
+	loc, ok := r.localities[key]
+	if !ok || loc.cep == "" {
+		return nil
+	}
+
+	address := &storage.Address{
+		CEP:       loc.cep,
+		Type:      storage.CEPTypeLocality,
+		CEPRanges: r.cepRanges[key],
+	}
+	r.place(address, key)
+
+	return address
+}
+
+// Street converts a LOG_LOGRADOURO record:
+// LOG_NU@UFE_SG@LOC_NU@BAI_NU_INI@BAI_NU_FIM@LOG_NO@LOG_COMPLEMENTO@CEP@TLO_TX@LOG_STA_TLO.
+func (r *Reader) Street(fields []string) *storage.Address {
+	name := fields[5]
+	if len(fields) < 10 || fields[9] != "N" {
+		name = strings.TrimSpace(fields[8] + " " + name)
+	}
+
+	// Complements such as "- até 999/1000" tell the numbers covered by the
+	// CEP, which upstream appends to the street name.
+	complement := strings.TrimSpace(strings.TrimLeft(fields[6], "- "))
+	location := name
+	if complement != "" {
+		location += " - " + complement
+	}
+
+	address := &storage.Address{
+		CEP:          fields[7],
+		State:        fields[1],
+		Neighborhood: r.neighborhoods[fields[3]],
+		Location:     location,
+		Type:         storage.CEPTypeStreet,
+		Complement:   complement,
+	}
+	r.place(address, fields[2])
+	address.Street, address.NumberRange = street.Parse(location)
+
+	return address
+}
+
+// BigUser converts a LOG_GRANDE_USUARIO record:
+// GRU_NU@UFE_SG@LOC_NU@BAI_NU@LOG_NU@GRU_NO@GRU_ENDERECO@CEP.
+func (r *Reader) BigUser(fields []string) *storage.Address {
+	return r.unit(fields[2], storage.CEPTypeBigUser, &storage.Address{
+		CEP:          fields[7],
+		State:        fields[1],
+		Neighborhood: r.neighborhoods[fields[3]],
+		Location:     fields[6],
+		Unit:         fields[5],
+	})
+}
+
+// Unit converts a LOG_UNID_OPER record:
+// UOP_NU@UFE_SG@LOC_NU@BAI_NU@LOG_NU@UOP_NO@UOP_ENDERECO@CEP@UOP_IN_CP.
+func (r *Reader) Unit(fields []string) *storage.Address {
+	return r.unit(fields[2], storage.CEPTypeUnit, &storage.Address{
+		CEP:          fields[7],
+		State:        fields[1],
+		Neighborhood: r.neighborhoods[fields[3]],
+		Location:     fields[6],
+		Unit:         fields[5],
+		POBoxRanges:  r.poBoxRanges[fields[0]],
+	})
+}
+
+// CommunityBox converts a LOG_CPC record:
+// CPC_NU@UFE_SG@LOC_NU@CPC_NO@CPC_ENDERECO@CEP.
+func (r *Reader) CommunityBox(fields []string) *storage.Address {
+	return r.unit(fields[2], storage.CEPTypeCommunityBox, &storage.Address{
+		CEP:      fields[5],
+		State:    fields[1],
+		Location: fields[4],
+		Unit:     fields[3],
+	})
+}
+
+func (r *Reader) unit(localityKey string, cepType storage.CEPType, address *storage.Address) *storage.Address {
+	address.Type = cepType
+	r.place(address, localityKey)
+	address.Street, address.NumberRange = street.Parse(address.Location)
+
+	return address
+}
+
+// place sets the state and city of an address. Districts and villages are
+// reported as subordinate to the city they belong to, as upstream does.
+func (r *Reader) place(address *storage.Address, localityKey string) {
+	loc, ok := r.localities[localityKey]
+	if !ok {
+		return
+	}
+
+	if address.State == "" {
+		address.State = loc.state
+	}
+	address.City = loc.name
+
+	if loc.kind != localityDistrict && loc.kind != localityVillage {
+		return
+	}
+
+	if parent, ok := r.localities[loc.parent]; ok {
+		address.City = parent.name
+		address.Subordinate = loc.name
+	}
+}
+
+func (r *Reader) localityKeys() []string {
+	keys := make([]string, 0, len(r.localities))
+	for key := range r.localities {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (r *Reader) streetFiles() []string {
+	var names []string
+	for name := range r.files.names {
+		if strings.HasPrefix(name, FileStreetPrefix) && strings.HasSuffix(name, ".TXT") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
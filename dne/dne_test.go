@@ -0,0 +1,159 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dne
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/insighted4/correios-cep/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// latin1 encodes records the way the eDNE is distributed.
+func latin1(t *testing.T, records ...string) *fstest.MapFile {
+	t.Helper()
+
+	data, err := charmap.ISO8859_1.NewEncoder().String(strings.Join(records, "\r\n") + "\r\n")
+	require.NoError(t, err)
+
+	return &fstest.MapFile{Data: []byte(data)}
+}
+
+func newTestFS(t *testing.T) fstest.MapFS {
+	return fstest.MapFS{
+		"LOG_LOCALIDADE.TXT": latin1(t,
+			"1@GO@Goiânia@@0@M@@GOIANIA@5208707",
+			"2@GO@Anápolis@75000000@0@M@@ANAPOLIS@5201108",
+			"3@GO@Interlândia@75149000@0@D@2@INTERLANDIA@",
+		),
+		"LOG_FAIXA_LOCALIDADE.TXT": latin1(t, "2@75000000@75159999@T"),
+		"LOG_BAIRRO.TXT":           latin1(t, "10@GO@1@Vila Mauá@V Maua"),
+		"log_logradouro_go.txt": latin1(t,
+			"100@GO@1@10@@General Couto de Magalhães@- até 999/1000@74323240@Avenida@S@Av Gen C Magalhaes",
+			"101@GO@1@10@@Anhanguera@@74323250@Avenida@N@Anhanguera",
+		),
+		"LOG_GRANDE_USUARIO.TXT": latin1(t, "200@GO@1@10@100@Edifício Triângulo@Avenida General Couto de Magalhães, 100@74323999@Ed Triangulo"),
+		"LOG_UNID_OPER.TXT":      latin1(t, "300@GO@1@10@100@AC Vila Mauá@Avenida General Couto de Magalhães, 200@74323970@S@AC V Maua"),
+		"LOG_FAIXA_UOP.TXT":      latin1(t, "300@1@500"),
+		"LOG_CPC.TXT":            latin1(t, "400@GO@3@CPC Interlândia@Rua 1, 10@75149970"),
+	}
+}
+
+func walk(t *testing.T, r *Reader) map[string]*storage.Address {
+	t.Helper()
+
+	addresses := make(map[string]*storage.Address)
+	require.NoError(t, r.Walk(context.Background(), func(address *storage.Address) error {
+		addresses[address.CEP] = address
+		return nil
+	}))
+
+	return addresses
+}
+
+func TestReader(t *testing.T) {
+	r, err := Open(newTestFS(t))
+	require.NoError(t, err)
+
+	addresses := walk(t, r)
+	assert.Len(t, addresses, 7)
+
+	assert.Equal(t, &storage.Address{
+		CEP:       "75000000",
+		State:     "GO",
+		City:      "Anápolis",
+		Type:      storage.CEPTypeLocality,
+		CEPRanges: []storage.CEPRange{{Start: "75000000", End: "75159999"}},
+	}, addresses["75000000"])
+
+	district := addresses["75149000"]
+	assert.Equal(t, "Anápolis", district.City)
+	assert.Equal(t, "Interlândia", district.Subordinate)
+
+	street := addresses["74323240"]
+	assert.Equal(t, "Goiânia", street.City)
+	assert.Equal(t, "Vila Mauá", street.Neighborhood)
+	assert.Equal(t, "Avenida General Couto de Magalhães - até 999/1000", street.Location)
+	assert.Equal(t, "até 999/1000", street.Complement)
+	assert.Equal(t, "Avenida General Couto de Magalhães", street.Street)
+	assert.Equal(t, &storage.NumberRange{Side: storage.SideBoth, To: 1000}, street.NumberRange)
+	assert.Equal(t, storage.CEPTypeStreet, street.Type)
+
+	assert.Equal(t, "Anhanguera", addresses["74323250"].Location)
+
+	bigUser := addresses["74323999"]
+	assert.Equal(t, "Edifício Triângulo", bigUser.Unit)
+	assert.Equal(t, storage.CEPTypeBigUser, bigUser.Type)
+
+	unit := addresses["74323970"]
+	assert.Equal(t, storage.CEPTypeUnit, unit.Type)
+	assert.Equal(t, []storage.POBoxRange{{Start: "1", End: "500"}}, unit.POBoxRanges)
+
+	box := addresses["75149970"]
+	assert.Equal(t, storage.CEPTypeCommunityBox, box.Type)
+	assert.Equal(t, "CPC Interlândia", box.Unit)
+	assert.Equal(t, "Interlândia", box.Subordinate)
+}
+
+func TestReader_UTF8(t *testing.T) {
+	fsys := newTestFS(t)
+	fsys["LOG_BAIRRO.TXT"] = &fstest.MapFile{Data: []byte("10@GO@1@Vila Mauá@V Maua\n")}
+
+	r, err := Open(fsys)
+	require.NoError(t, err)
+	assert.Equal(t, "Vila Mauá", walk(t, r)["74323240"].Neighborhood)
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	fsys := newTestFS(t)
+	delete(fsys, "LOG_BAIRRO.TXT")
+	_, err := Open(fsys)
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+
+	fsys = newTestFS(t)
+	fsys["LOG_LOCALIDADE.TXT"] = latin1(t, "1@GO@Goiânia")
+	_, err = Open(fsys)
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+	assert.Contains(t, err.Error(), "LOG_LOCALIDADE.TXT line 1")
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New(nil)
+
+	require.NoError(t, s.CreateAddress(ctx, &storage.Address{CEP: "74323240", Neighborhood: "Outdated"}))
+
+	r, err := Open(newTestFS(t))
+	require.NoError(t, err)
+
+	stats, err := Import(ctx, r, s)
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Created: 6, Updated: 1}, stats)
+
+	address, err := s.GetAddress(ctx, "74323240")
+	require.NoError(t, err)
+	assert.Equal(t, "Vila Mauá", address.Neighborhood)
+
+	stats, err = Import(ctx, r, s)
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Updated: 7}, stats)
+}
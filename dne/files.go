@@ -0,0 +1,116 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dne
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// files reads the files of an eDNE directory.
+type files struct {
+	fsys fs.FS
+	// names maps upper-cased file names to their names in fsys, since
+	// distributions differ in the case of their file names.
+	names map[string]string
+}
+
+func openFiles(fsys fs.FS) (files, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return files{}, err
+	}
+
+	f := files{fsys: fsys, names: make(map[string]string, len(entries))}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			f.names[strings.ToUpper(entry.Name())] = entry.Name()
+		}
+	}
+
+	return f, nil
+}
+
+func (f files) has(name string) bool {
+	_, ok := f.names[name]
+	return ok
+}
+
+// read calls fn with the fields of every record of the named file, which
+// must have at least minFields fields. Missing files have no records.
+func (f files) read(name string, minFields int, fn func(fields []string) error) error {
+	const op errors.Op = "dne.read"
+
+	actual, ok := f.names[name]
+	if !ok {
+		return nil
+	}
+
+	file, err := f.fsys.Open(actual)
+	if err != nil {
+		return errors.E(op, errors.KindUnexpected, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(decode(scanner.Bytes()), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, Separator)
+		if len(fields) < minFields {
+			return errors.E(op, errors.KindBadRequest, fmt.Sprintf("%s line %d has %d fields, expected %d", actual, n, len(fields), minFields))
+		}
+
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+
+		if err := fn(fields); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return errors.E(op, errors.KindUnexpected, fmt.Sprintf("failed to read %s, %v", actual, err))
+	}
+
+	return nil
+}
+
+// decode converts a line to UTF-8. The eDNE is distributed in ISO-8859-1,
+// but files converted to UTF-8 are accepted as well.
+func decode(line []byte) string {
+	if utf8.Valid(line) {
+		return string(line)
+	}
+
+	decoded, err := charmap.ISO8859_1.NewDecoder().Bytes(line)
+	if err != nil {
+		return string(line)
+	}
+
+	return string(decoded)
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dne
+
+import (
+	"context"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/pkg/log"
+	"github.com/insighted4/correios-cep/storage"
+)
+
+// Stats counts the addresses written by an import.
+type Stats struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+}
+
+// Import stores every address of the eDNE in bulk, replacing the addresses
+// already stored under the same CEPs.
+func Import(ctx context.Context, r *Reader, s storage.Storage) (Stats, error) {
+	const op errors.Op = "dne.Import"
+
+	logger := log.WithField("component", "dne")
+
+	bulk, err := s.BulkUpsertAddresses(ctx, r.All(ctx), func(bulk storage.BulkStats) {
+		logger.Infof("Imported %d addresses", bulk.Created+bulk.Updated)
+	})
+	stats := Stats{Created: bulk.Created, Updated: bulk.Updated}
+	if err != nil {
+		return stats, errors.E(op, err)
+	}
+
+	return stats, nil
+}
+
+// ApplyDelta applies the changes of d and records version in a single
+// transaction, so a delta is either fully applied or not at all. It fails
+// with KindAlreadyExists when version was already applied.
+func ApplyDelta(ctx context.Context, r *Reader, d *Delta, s storage.Storage, version string) (Stats, error) {
+	const op errors.Op = "dne.ApplyDelta"
+
+	var stats Stats
+	err := s.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.CreateDeltaVersion(ctx, &storage.DeltaVersion{Version: version}); err != nil {
+			return errors.E(op, err)
+		}
+
+		return r.WalkDelta(ctx, d, func(change Change) error {
+			if change.Op == OpDelete {
+				err := tx.DeleteAddress(ctx, change.Address.CEP)
+				if errors.Is(err, errors.KindNotFound) {
+					return nil
+				}
+				if err != nil {
+					return errors.E(op, err)
+				}
+
+				stats.Deleted++
+				return nil
+			}
+
+			created, err := upsert(ctx, tx, change.Address)
+			if err != nil {
+				return errors.E(op, err)
+			}
+
+			if created {
+				stats.Created++
+			} else {
+				stats.Updated++
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return stats, nil
+}
+
+// upsert replaces the stored address with the same CEP, or creates address
+// when there is none. Updating first keeps failed inserts from aborting
+// database transactions.
+func upsert(ctx context.Context, s storage.Storage, address *storage.Address) (bool, error) {
+	err := s.UpdateAddress(ctx, address.CEP, func(old *storage.Address) (*storage.Address, error) {
+		address.CreatedAt = old.CreatedAt
+		return address, nil
+	})
+	if !errors.Is(err, errors.KindNotFound) {
+		return false, err
+	}
+
+	return true, s.CreateAddress(ctx, address)
+}
@@ -0,0 +1,320 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package export encodes addresses as CSV, newline-delimited JSON or Parquet.
+//
+// Addresses are encoded as they are read, so exports of any size stream with
+// flat memory. CSV and Parquet have a column per field, with the nested
+// fields (children, ranges) encoded as JSON.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+	"time"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/parquet-go/parquet-go"
+)
+
+// Format is an encoding of exported addresses.
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatNDJSON  Format = "ndjson"
+	FormatParquet Format = "parquet"
+)
+
+var formats = []Format{
+	FormatCSV,
+	FormatNDJSON,
+	FormatParquet,
+}
+
+// rowGroupSize is the number of addresses per Parquet row group, which the
+// writer holds in memory until the group is complete.
+const rowGroupSize = 50000
+
+// Formats returns every supported format.
+func Formats() []Format {
+	return append([]Format(nil), formats...)
+}
+
+// ParseFormat validates s as a format, ignoring case.
+func ParseFormat(s string) (Format, error) {
+	const op errors.Op = "export.ParseFormat"
+
+	f := Format(strings.ToLower(s))
+	for _, known := range formats {
+		if f == known {
+			return f, nil
+		}
+	}
+
+	return "", errors.E(op, errors.KindBadRequest, fmt.Sprintf("invalid export format %q", s))
+}
+
+// ContentType returns the media type of f.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+func (f Format) String() string {
+	return string(f)
+}
+
+// Write encodes every address of addresses to w in format f, returning how
+// many were written. It stops at the first error, returning it.
+func Write(w io.Writer, f Format, addresses iter.Seq2[*storage.Address, error]) (int, error) {
+	const op errors.Op = "export.Write"
+
+	var enc encoder
+	switch f {
+	case FormatCSV:
+		enc = newCSVEncoder(w)
+	case FormatNDJSON:
+		enc = newNDJSONEncoder(w)
+	case FormatParquet:
+		enc = newParquetEncoder(w)
+	default:
+		return 0, errors.E(op, errors.KindBadRequest, fmt.Sprintf("invalid export format %q", f))
+	}
+
+	n := 0
+	for address, err := range addresses {
+		if err != nil {
+			return n, errors.E(op, err)
+		}
+
+		if err := enc.encode(address); err != nil {
+			return n, errors.E(op, errors.KindUnexpected, err)
+		}
+		n++
+	}
+
+	if err := enc.close(); err != nil {
+		return n, errors.E(op, errors.KindUnexpected, err)
+	}
+
+	return n, nil
+}
+
+type encoder interface {
+	encode(address *storage.Address) error
+	close() error
+}
+
+// record is the flat layout of an address in CSV and Parquet.
+type record struct {
+	CEP          string     `parquet:"cep"`
+	State        string     `parquet:"state"`
+	City         string     `parquet:"city"`
+	Neighborhood string     `parquet:"neighborhood"`
+	Location     string     `parquet:"location"`
+	Unit         string     `parquet:"unit"`
+	Type         string     `parquet:"type"`
+	Complement   string     `parquet:"complement"`
+	Subordinate  string     `parquet:"subordinate"`
+	Status       string     `parquet:"status"`
+	Street       string     `parquet:"street"`
+	NumberRange  string     `parquet:"number_range"`
+	POBoxRanges  string     `parquet:"po_box_ranges"`
+	CEPRanges    string     `parquet:"cep_ranges"`
+	Children     string     `parquet:"children"`
+	CreatedAt    *time.Time `parquet:"created_at,optional"`
+	UpdatedAt    *time.Time `parquet:"updated_at,optional"`
+}
+
+// header names the CSV columns, in the order of record.
+var header = []string{
+	"cep",
+	"state",
+	"city",
+	"neighborhood",
+	"location",
+	"unit",
+	"type",
+	"complement",
+	"subordinate",
+	"status",
+	"street",
+	"number_range",
+	"po_box_ranges",
+	"cep_ranges",
+	"children",
+	"created_at",
+	"updated_at",
+}
+
+func newRecord(address *storage.Address) (record, error) {
+	r := record{
+		CEP:          address.CEP,
+		State:        address.State,
+		City:         address.City,
+		Neighborhood: address.Neighborhood,
+		Location:     address.Location,
+		Unit:         address.Unit,
+		Type:         address.Type.String(),
+		Complement:   address.Complement,
+		Subordinate:  address.Subordinate,
+		Status:       address.Status,
+		Street:       address.Street,
+		CreatedAt:    address.CreatedAt,
+		UpdatedAt:    address.UpdatedAt,
+	}
+
+	for _, field := range []struct {
+		dst   *string
+		value any
+		empty bool
+	}{
+		{&r.NumberRange, address.NumberRange, address.NumberRange == nil},
+		{&r.POBoxRanges, address.POBoxRanges, len(address.POBoxRanges) == 0},
+		{&r.CEPRanges, address.CEPRanges, len(address.CEPRanges) == 0},
+		{&r.Children, address.Children, len(address.Children) == 0},
+	} {
+		if field.empty {
+			continue
+		}
+
+		b, err := json.Marshal(field.value)
+		if err != nil {
+			return record{}, err
+		}
+		*field.dst = string(b)
+	}
+
+	return r, nil
+}
+
+type csvEncoder struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) encode(address *storage.Address) error {
+	if !e.wroteHeader {
+		if err := e.w.Write(header); err != nil {
+			return err
+		}
+		e.wroteHeader = true
+	}
+
+	r, err := newRecord(address)
+	if err != nil {
+		return err
+	}
+
+	return e.w.Write([]string{
+		r.CEP,
+		r.State,
+		r.City,
+		r.Neighborhood,
+		r.Location,
+		r.Unit,
+		r.Type,
+		r.Complement,
+		r.Subordinate,
+		r.Status,
+		r.Street,
+		r.NumberRange,
+		r.POBoxRanges,
+		r.CEPRanges,
+		r.Children,
+		formatTime(r.CreatedAt),
+		formatTime(r.UpdatedAt),
+	})
+}
+
+func (e *csvEncoder) close() error {
+	// An empty export still tells the columns.
+	if !e.wroteHeader {
+		if err := e.w.Write(header); err != nil {
+			return err
+		}
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func newNDJSONEncoder(w io.Writer) *ndjsonEncoder {
+	return &ndjsonEncoder{enc: json.NewEncoder(w)}
+}
+
+func (e *ndjsonEncoder) encode(address *storage.Address) error {
+	return e.enc.Encode(address)
+}
+
+func (e *ndjsonEncoder) close() error {
+	return nil
+}
+
+type parquetEncoder struct {
+	w *parquet.GenericWriter[record]
+}
+
+func newParquetEncoder(w io.Writer) *parquetEncoder {
+	return &parquetEncoder{
+		w: parquet.NewGenericWriter[record](w,
+			parquet.Compression(&parquet.Snappy),
+			parquet.MaxRowsPerRowGroup(rowGroupSize),
+		),
+	}
+}
+
+func (e *parquetEncoder) encode(address *storage.Address) error {
+	r, err := newRecord(address)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.w.Write([]record{r})
+	return err
+}
+
+func (e *parquetEncoder) close() error {
+	return e.w.Close()
+}
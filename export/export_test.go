@@ -0,0 +1,153 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAddresses() []*storage.Address {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	return []*storage.Address{
+		{
+			CEP:          "74323240",
+			State:        "GO",
+			City:         "Goiânia",
+			Neighborhood: "Setor Sudoeste",
+			Location:     "Avenida General Couto de Magalhães - até 999/1000",
+			Type:         storage.CEPTypeStreet,
+			Street:       "Avenida General Couto de Magalhães",
+			NumberRange:  &storage.NumberRange{Side: storage.SideBoth, To: 1000},
+			CreatedAt:    &now,
+			UpdatedAt:    &now,
+		},
+		{
+			CEP:       "75000000",
+			State:     "GO",
+			City:      "Anápolis",
+			Type:      storage.CEPTypeLocality,
+			CEPRanges: []storage.CEPRange{{Start: "75000001", End: "75159999"}},
+		},
+	}
+}
+
+func seq(addresses []*storage.Address, err error) iter.Seq2[*storage.Address, error] {
+	return func(yield func(*storage.Address, error) bool) {
+		for _, address := range addresses {
+			if !yield(address, nil) {
+				return
+			}
+		}
+
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+func TestWrite_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := Write(&buf, FormatCSV, seq(testAddresses(), nil))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, header, rows[0])
+	assert.Equal(t, []string{
+		"74323240", "GO", "Goiânia", "Setor Sudoeste", "Avenida General Couto de Magalhães - até 999/1000", "",
+		"logradouro", "", "", "", "Avenida General Couto de Magalhães", `{"side":"both","to":1000}`, "", "", "",
+		"2023-10-01T12:00:00Z", "2023-10-01T12:00:00Z",
+	}, rows[1])
+	assert.Equal(t, `[{"start":"75000001","end":"75159999"}]`, rows[2][13])
+}
+
+func TestWrite_CSVEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := Write(&buf, FormatCSV, seq(nil, nil))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{header}, rows)
+}
+
+func TestWrite_NDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := Write(&buf, FormatNDJSON, seq(testAddresses(), nil))
+	require.NoError(t, err)
+
+	var ceps []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var address storage.Address
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &address))
+		ceps = append(ceps, address.CEP)
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, []string{"74323240", "75000000"}, ceps)
+}
+
+func TestWrite_Parquet(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := Write(&buf, FormatParquet, seq(testAddresses(), nil))
+	require.NoError(t, err)
+
+	records, err := parquet.Read[record](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "Goiânia", records[0].City)
+	assert.Equal(t, `{"side":"both","to":1000}`, records[0].NumberRange)
+	require.NotNil(t, records[0].CreatedAt)
+	assert.True(t, testAddresses()[0].CreatedAt.Equal(*records[0].CreatedAt))
+	assert.Equal(t, "localidade", records[1].Type)
+	assert.Nil(t, records[1].CreatedAt)
+}
+
+func TestWrite_Error(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := Write(&buf, FormatNDJSON, seq(testAddresses()[:1], errors.E(errors.Op("test"), errors.KindUnexpected, "connection reset")))
+	assert.True(t, errors.Is(err, errors.KindUnexpected), "expected KindUnexpected, got %v", err)
+	assert.Equal(t, 1, n)
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range Formats() {
+		got, err := ParseFormat(string(f))
+		require.NoError(t, err)
+		assert.Equal(t, f, got)
+	}
+
+	got, err := ParseFormat("CSV")
+	require.NoError(t, err)
+	assert.Equal(t, FormatCSV, got)
+
+	_, err = ParseFormat("xml")
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+}
@@ -19,9 +19,11 @@ CREATE TABLE IF NOT EXISTS addresses
     city         TEXT,
     neighborhood TEXT,
     location     TEXT,
-    children    JSONB,
+    children     JSONB,
     created_at   TIMESTAMPTZ DEFAULT now() NOT NULL,
     updated_at   TIMESTAMPTZ DEFAULT now() NOT NULL
 );
 
 CREATE INDEX IF NOT EXISTS addresses_state_idx ON addresses (state);
+
+CREATE INDEX IF NOT EXISTS addresses_updated_at_idx ON addresses (updated_at);
@@ -0,0 +1,25 @@
+-- Copyright 2023 The Correios CEP Admin Authors
+--
+-- Licensed under the AGPL, Version 3.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--  https://www.gnu.org/licenses/agpl-3.0.en.html
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+DROP INDEX IF EXISTS addresses_state_type_idx;
+
+ALTER TABLE addresses DROP COLUMN IF EXISTS number_range;
+ALTER TABLE addresses DROP COLUMN IF EXISTS street;
+ALTER TABLE addresses DROP COLUMN IF EXISTS cep_ranges;
+ALTER TABLE addresses DROP COLUMN IF EXISTS po_box_ranges;
+ALTER TABLE addresses DROP COLUMN IF EXISTS status;
+ALTER TABLE addresses DROP COLUMN IF EXISTS subordinate;
+ALTER TABLE addresses DROP COLUMN IF EXISTS complement;
+ALTER TABLE addresses DROP COLUMN IF EXISTS type;
+ALTER TABLE addresses DROP COLUMN IF EXISTS unit;
@@ -0,0 +1,37 @@
+-- Copyright 2023 The Correios CEP Admin Authors
+--
+-- Licensed under the AGPL, Version 3.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--  https://www.gnu.org/licenses/agpl-3.0.en.html
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS unit TEXT DEFAULT '' NOT NULL;
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS type TEXT DEFAULT '' NOT NULL;
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS complement TEXT DEFAULT '' NOT NULL;
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS subordinate TEXT DEFAULT '' NOT NULL;
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS status TEXT DEFAULT '' NOT NULL;
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS po_box_ranges JSONB;
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS cep_ranges JSONB;
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS street TEXT DEFAULT '' NOT NULL;
+ALTER TABLE addresses ADD COLUMN IF NOT EXISTS number_range JSONB;
+
+-- Replace the upstream tipoCep codes stored before CEP types were classified.
+UPDATE addresses
+SET type = CASE type
+               WHEN '1' THEN 'localidade'
+               WHEN '2' THEN 'logradouro'
+               WHEN '3' THEN 'caixa_postal_comunitaria'
+               WHEN '5' THEN 'grande_usuario'
+               WHEN '6' THEN 'unidade_operacional'
+               ELSE ''
+    END
+WHERE type ~ '^[0-9]+$';
+
+CREATE INDEX IF NOT EXISTS addresses_state_type_idx ON addresses (lower(state), type);
@@ -0,0 +1,26 @@
+-- Copyright 2023 The Correios CEP Admin Authors
+--
+-- Licensed under the AGPL, Version 3.0 (the "License");
+-- you may not use this file except in compliance with the License.
+-- You may obtain a copy of the License at
+--
+--  https://www.gnu.org/licenses/agpl-3.0.en.html
+--
+-- Unless required by applicable law or agreed to in writing, software
+-- distributed under the License is distributed on an "AS IS" BASIS,
+-- WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+-- See the License for the specific language governing permissions and
+-- limitations under the License.
+
+-- Accent-insensitive address search. unaccent is only STABLE, since its
+-- dictionary may change, so it is wrapped to be usable in an index.
+CREATE EXTENSION IF NOT EXISTS unaccent;
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE OR REPLACE FUNCTION address_search_text(city TEXT, neighborhood TEXT, location TEXT) RETURNS TEXT AS
+$$
+SELECT lower(public.unaccent('public.unaccent'::regdictionary,
+                             coalesce(city, '') || ' ' || coalesce(neighborhood, '') || ' ' || coalesce(location, '')))
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE;
+
+CREATE INDEX IF NOT EXISTS addresses_search_idx ON addresses USING gin (address_search_text(city, neighborhood, location) gin_trgm_ops);
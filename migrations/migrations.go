@@ -0,0 +1,114 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package migrations embeds the numbered schema migrations of the PostgreSQL
+// storage.
+//
+// Every migration is a pair of files, NNNN_name.up.sql and
+// NNNN_name.down.sql, applied in the order of their numbers. Migrations are
+// written to also apply to databases created before they were versioned.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+var filename = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a versioned change to the schema.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// All returns the migrations embedded in the binary, ordered by version.
+func All() ([]Migration, error) {
+	return Load(files)
+}
+
+// Load returns the migrations of fsys, ordered by version. Every version
+// must have both an up and a down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	const op errors.Op = "migrations.Load"
+
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, errors.E(op, errors.KindUnexpected, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, name := range names {
+		match := filename.FindStringSubmatch(name)
+		if match == nil {
+			return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("invalid migration file name %q", name))
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version < 1 {
+			return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("invalid migration version in %q", name))
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+
+		if m.Name != match[2] {
+			return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("migration %d is named both %q and %q", version, m.Name, match[2]))
+		}
+
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, errors.E(op, errors.KindUnexpected, err)
+		}
+
+		if match[3] == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("migration %s must have both an up and a down file", m))
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
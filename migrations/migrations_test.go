@@ -0,0 +1,72 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAll(t *testing.T) {
+	migrations, err := All()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.Equal(t, int64(i+1), m.Version, "migrations must be numbered without gaps")
+		assert.NotEmpty(t, m.Up, m.String())
+		assert.NotEmpty(t, m.Down, m.String())
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"0002_add_column.up.sql":     {Data: []byte("ALTER TABLE t ADD COLUMN c TEXT;")},
+		"0002_add_column.down.sql":   {Data: []byte("ALTER TABLE t DROP COLUMN c;")},
+		"0001_create_table.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+		"0001_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+	})
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+
+	assert.Equal(t, Migration{Version: 1, Name: "create_table", Up: "CREATE TABLE t (id INT);", Down: "DROP TABLE t;"}, migrations[0])
+	assert.Equal(t, "0002_add_column", migrations[1].String())
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"missing down": {
+			"0001_create_table.up.sql": {Data: []byte("CREATE TABLE t (id INT);")},
+		},
+		"invalid name": {
+			"create_table.up.sql": {Data: []byte("CREATE TABLE t (id INT);")},
+		},
+		"conflicting names": {
+			"0001_create_table.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+			"0001_create_other.down.sql": {Data: []byte("DROP TABLE t;")},
+		},
+		"zero version": {
+			"0000_create_table.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+			"0000_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+		},
+	} {
+		_, err := Load(fsys)
+		assert.True(t, errors.Is(err, errors.KindUnexpected), "%s: expected KindUnexpected, got %v", name, err)
+	}
+}
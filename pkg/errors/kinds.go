@@ -25,6 +25,7 @@ const (
 	KindRateLimit      = http.StatusTooManyRequests
 	KindNotImplemented = http.StatusNotImplemented
 	KindRedirect       = http.StatusMovedPermanently
+	KindUnavailable    = http.StatusServiceUnavailable
 )
 
 // IsNotFoundErr helper function for KindNotFound.
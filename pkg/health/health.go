@@ -23,13 +23,31 @@ type Checker interface {
 	Check(ctx context.Context) error
 }
 
+// Detailer is implemented by checkers that report details alongside their
+// status.
+type Detailer interface {
+	Details() interface{}
+}
+
 // NewCustomHealthCheckFunc returns a new health check function.
 func NewCustomHealthCheckFunc(checker Checker, now func() time.Time) func(context.Context) (details interface{}, err error) {
 	return func(ctx context.Context) (details interface{}, err error) {
+		if detailer, ok := checker.(Detailer); ok {
+			details = detailer.Details()
+		}
+
 		if err := checker.Check(ctx); err != nil {
-			return nil, err
+			return details, err
 		}
 
-		return nil, nil
+		return details, nil
+	}
+}
+
+// NewDetailsCheckFunc returns a health check function that reports the
+// details of detailer and never fails.
+func NewDetailsCheckFunc(detailer Detailer) func(context.Context) (details interface{}, err error) {
+	return func(ctx context.Context) (details interface{}, err error) {
+		return detailer.Details(), nil
 	}
 }
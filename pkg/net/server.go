@@ -16,6 +16,7 @@ package net
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -71,6 +72,10 @@ type server struct {
 	httpServer *http.Server
 	Shutdown   func()
 
+	// cancel cancels the context of the requests still running after the
+	// shutdown timeout.
+	cancel context.CancelFunc
+
 	// Exit chan for graceful Shutdown
 	Exit chan chan error
 }
@@ -100,7 +105,9 @@ func NewServer(httpCfg HTTPServerConfig, handler http.Handler, shutdownFn func()
 		httpCfg.Addr = DefaultAddr
 	}
 
+	baseCtx, cancel := context.WithCancel(context.Background())
 	httpServer := &http.Server{
+		BaseContext:    func(net.Listener) context.Context { return baseCtx },
 		Handler:        handler,
 		Addr:           httpCfg.Addr,
 		MaxHeaderBytes: httpCfg.MaxHeaderBytes,
@@ -113,6 +120,7 @@ func NewServer(httpCfg HTTPServerConfig, handler http.Handler, shutdownFn func()
 		cfg:        httpCfg,
 		httpServer: httpServer,
 		Shutdown:   shutdownFn,
+		cancel:     cancel,
 		Exit:       make(chan chan error),
 	}
 }
@@ -143,7 +151,9 @@ func (s *server) start() error {
 		// Stop HTTP Server
 		if s.httpServer != nil {
 			log.Infof("Stopping HTTP Server on %s", s.httpServer.Addr)
-			exit <- s.httpServer.Shutdown(ctx)
+			err := s.httpServer.Shutdown(ctx)
+			s.cancel()
+			exit <- err
 			return
 		}
 
@@ -0,0 +1,153 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package refresh keeps cached addresses in sync with the upstream provider,
+// since Correios renames streets and neighborhoods over time.
+package refresh
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/insighted4/correios-cep/correios"
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/pkg/log"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/sirupsen/logrus"
+)
+
+const DefaultBatchSize = 100
+
+// Address looks up cep upstream and replaces the cached address with the
+// result, including any correction made to it through the API. When the CEP
+// is no longer known upstream, the cached address is kept and only its
+// UpdatedAt is bumped, so it is not retried on every sweep.
+func Address(ctx context.Context, c correios.Correios, s storage.Storage, cep string) (*storage.Address, error) {
+	const op errors.Op = "refresh.Address"
+
+	address, err := c.Lookup(ctx, cep)
+	if err == nil && address == nil {
+		err = errors.E(op, errors.KindNotFound, fmt.Sprintf("cep %s not found", cep))
+	}
+	if errors.Is(err, errors.KindNotFound) {
+		if err := s.UpdateAddress(ctx, cep, func(old *storage.Address) (*storage.Address, error) {
+			return old, nil
+		}); err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		return nil, errors.E(op, err)
+	}
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	address.CEP = cep
+	if err := s.UpdateAddress(ctx, cep, func(old *storage.Address) (*storage.Address, error) {
+		address.CreatedAt = old.CreatedAt
+		return address, nil
+	}); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return address, nil
+}
+
+type Config struct {
+	Correios correios.Correios
+	Storage  storage.Storage
+
+	// MaxAge is how long an address is served before it is refreshed.
+	MaxAge time.Duration
+
+	// Interval between sweeps.
+	Interval time.Duration
+
+	// BatchSize is the maximum number of addresses refreshed per sweep.
+	// Defaults to DefaultBatchSize.
+	BatchSize int
+
+	// If specified, the worker will use this function for determining time.
+	Now func() time.Time
+}
+
+// Worker periodically refreshes the addresses that have been cached the
+// longest.
+type Worker struct {
+	cfg    Config
+	logger logrus.FieldLogger
+}
+
+func NewWorker(cfg Config) *Worker {
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
+	return &Worker{
+		cfg:    cfg,
+		logger: log.WithField("component", "refresh"),
+	}
+}
+
+// Run sweeps every Interval until ctx is done.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := w.Sweep(ctx); err != nil {
+				w.logger.WithError(err).Warn("unable to refresh addresses")
+			}
+		}
+	}
+}
+
+// Sweep refreshes one batch of stale addresses, returning how many were
+// updated. It stops early when upstream fails unexpectedly, since the
+// remaining lookups would likely fail as well.
+func (w *Worker) Sweep(ctx context.Context) (int, error) {
+	const op errors.Op = "refresh.Sweep"
+
+	addresses, err := w.cfg.Storage.ListStaleAddresses(ctx, w.cfg.Now().Add(-w.cfg.MaxAge), w.cfg.BatchSize)
+	if err != nil {
+		return 0, errors.E(op, err)
+	}
+
+	var refreshed int
+	for _, address := range addresses {
+		if _, err := Address(ctx, w.cfg.Correios, w.cfg.Storage, address.CEP); err != nil {
+			if errors.Is(err, errors.KindNotFound) {
+				w.logger.WithField("cep", address.CEP).Warn("cached cep no longer found upstream")
+				continue
+			}
+
+			return refreshed, errors.E(op, err)
+		}
+
+		refreshed++
+	}
+
+	w.logger.WithField("refreshed", refreshed).Debug("refreshed stale addresses")
+
+	return refreshed, nil
+}
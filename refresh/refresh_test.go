@@ -0,0 +1,137 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package refresh
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/insighted4/correios-cep/correios"
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/insighted4/correios-cep/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCorreios struct {
+	addresses map[string]*storage.Address
+	err       error
+}
+
+func (f *fakeCorreios) Check(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeCorreios) Lookup(ctx context.Context, cep string) (*storage.Address, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	address, ok := f.addresses[cep]
+	if !ok {
+		return nil, errors.E("fakeCorreios.Lookup", errors.KindNotFound, "not found")
+	}
+	if address == nil {
+		return nil, nil
+	}
+
+	clone := *address
+	return &clone, nil
+}
+
+func (f *fakeCorreios) Search(ctx context.Context, query correios.SearchQuery) ([]*storage.Address, error) {
+	return nil, errors.E("fakeCorreios.Search", errors.KindNotImplemented, "search not supported")
+}
+
+func TestWorker_Sweep(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	s := memory.New(clock)
+	for _, cep := range []string{"74323240", "01003900", "99999999"} {
+		require.NoError(t, s.CreateAddress(ctx, &storage.Address{CEP: cep, State: "GO", Neighborhood: "old"}))
+		now = now.Add(time.Minute)
+	}
+
+	c := &fakeCorreios{addresses: map[string]*storage.Address{
+		"74323240": {State: "GO", Neighborhood: "new"},
+		"01003900": {State: "SP", Neighborhood: "new"},
+	}}
+
+	now = now.Add(time.Hour)
+	w := NewWorker(Config{Correios: c, Storage: s, MaxAge: time.Hour, BatchSize: 2, Now: clock})
+
+	// Only the two oldest addresses are refreshed.
+	refreshed, err := w.Sweep(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, refreshed)
+
+	address, err := s.GetAddress(ctx, "01003900")
+	require.NoError(t, err)
+	assert.Equal(t, "new", address.Neighborhood)
+	assert.Equal(t, now, *address.UpdatedAt)
+
+	// Addresses unknown upstream are kept, and not retried until stale again.
+	refreshed, err = w.Sweep(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, refreshed)
+
+	address, err = s.GetAddress(ctx, "99999999")
+	require.NoError(t, err)
+	assert.Equal(t, "old", address.Neighborhood)
+	assert.Equal(t, now, *address.UpdatedAt)
+
+	refreshed, err = w.Sweep(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, refreshed)
+}
+
+func TestWorker_SweepUpstreamFailure(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	s := memory.New(clock)
+	require.NoError(t, s.CreateAddress(ctx, &storage.Address{CEP: "74323240", State: "GO", Neighborhood: "old"}))
+
+	now = now.Add(2 * time.Hour)
+	c := &fakeCorreios{err: errors.E("fakeCorreios.Lookup", errors.KindUnexpected, "unavailable")}
+	w := NewWorker(Config{Correios: c, Storage: s, MaxAge: time.Hour, Now: clock})
+
+	refreshed, err := w.Sweep(ctx)
+	assert.True(t, errors.Is(err, errors.KindUnexpected), "expected KindUnexpected, got %v", err)
+	assert.Equal(t, 0, refreshed)
+
+	address, err := s.GetAddress(ctx, "74323240")
+	require.NoError(t, err)
+	assert.Equal(t, "old", address.Neighborhood)
+}
+
+func TestAddress_NilAnswer(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New(nil)
+	require.NoError(t, s.CreateAddress(ctx, &storage.Address{CEP: "74323240", State: "GO", Neighborhood: "old"}))
+
+	c := &fakeCorreios{addresses: map[string]*storage.Address{"74323240": nil}}
+	_, err := Address(ctx, c, s, "74323240")
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+
+	address, err := s.GetAddress(ctx, "74323240")
+	require.NoError(t, err)
+	assert.Equal(t, "old", address.Neighborhood)
+}
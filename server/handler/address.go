@@ -15,11 +15,13 @@
 package handler
 
 import (
-	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/insighted4/correios-cep/cep"
 	"github.com/insighted4/correios-cep/correios"
 	"github.com/insighted4/correios-cep/pkg/errors"
 	"github.com/insighted4/correios-cep/storage"
@@ -35,6 +37,7 @@ func listAddressHandler(s storage.Storage, log logrus.FieldLogger) gin.HandlerFu
 	type ListAddressesRequest struct {
 		PaginationRequest
 		State string `json:"state" form:"state"`
+		Type  string `json:"type" form:"type"`
 	}
 
 	const op errors.Op = "handler.handleListAddresses"
@@ -45,9 +48,16 @@ func listAddressHandler(s storage.Storage, log logrus.FieldLogger) gin.HandlerFu
 			return
 		}
 
+		cepType, err := storage.ParseCEPType(form.Type)
+		if err != nil {
+			abortWithError(ctx, errors.E(op, err), nil)
+			return
+		}
+
 		params := storage.ListParams{
 			Pagination: storage.NewPagination(form.PerPage, form.Page),
 			State:      form.State,
+			Type:       cepType,
 		}
 
 		result, err := s.ListAddresses(ctx, params)
@@ -61,43 +71,65 @@ func listAddressHandler(s storage.Storage, log logrus.FieldLogger) gin.HandlerFu
 	}
 }
 
-func getAddressHandler(c correios.Correios, s storage.Storage, log logrus.FieldLogger) gin.HandlerFunc {
-	const op errors.Op = "handler.handleListAddresses"
+// DefaultAutocompleteLimit is the number of suggestions returned when the
+// request does not ask for a limit.
+const DefaultAutocompleteLimit = 10
 
-	getAddressFn := func(ctx context.Context, cep string) (*storage.Address, error) {
-		addr, err := s.GetAddress(ctx, cep)
-		if err == nil {
-			return addr, nil
+func autocompleteHandler(s storage.Storage, log logrus.FieldLogger) gin.HandlerFunc {
+	type AutocompleteRequest struct {
+		Query string `json:"q" form:"q"`
+		State string `json:"state" form:"state"`
+		Limit int    `json:"limit" form:"limit"`
+	}
+
+	const op errors.Op = "handler.handleAutocomplete"
+	return func(ctx *gin.Context) {
+		var form AutocompleteRequest
+		if err := ctx.ShouldBind(&form); err != nil {
+			abortWithError(ctx, errors.E(op, errors.KindBadRequest, err), nil)
+			return
 		}
 
-		if errors.Is(err, errors.KindUnexpected) {
-			return nil, err
+		if form.Limit < 1 {
+			form.Limit = DefaultAutocompleteLimit
 		}
 
-		addr, err = c.Lookup(ctx, cep)
-		if err != nil && !errors.Is(err, errors.KindNotFound) {
-			return nil, err
+		params := storage.SearchParams{
+			Query: form.Query,
+			State: form.State,
+			Limit: min(form.Limit, storage.PaginationLimit),
 		}
 
-		if err := s.CreateAddress(ctx, addr); err != nil {
-			return nil, err
+		result, err := s.SearchAddresses(ctx, params)
+		if err != nil {
+			log.Errorf("failed to search stored addresses: %v", err)
+			abortWithError(ctx, err, nil)
+			return
 		}
 
-		return addr, nil
+		ctx.JSON(http.StatusOK, result)
 	}
+}
 
+func getAddressHandler(r *resolver, log logrus.FieldLogger) gin.HandlerFunc {
+	const op errors.Op = "handler.handleGetAddress"
 	return func(ctx *gin.Context) {
-		cep := ctx.Param("cep")
-		result, err := getAddressFn(ctx, cep)
+		code, err := cep.Parse(ctx.Param("cep"))
+		if err != nil {
+			abortWithError(ctx, errors.E(op, err), nil)
+			return
+		}
+
+		result, err := r.resolve(ctx.Request.Context(), code)
 		switch {
 		case err == nil:
 			ctx.JSON(http.StatusOK, result)
 		case errors.Is(err, errors.KindNotFound):
-			log.Infof("address not found: cep %s", cep)
+			log.Infof("address not found: cep %s", code)
 			abortWithError(
 				ctx, errors.E(op, errors.KindNotFound, "address not found"),
 				fmt.Sprintf("CEP %s not found",
-					cep),
+					code.Format()),
 			)
 		case err != nil:
 			log.Errorf("failed to get addresses: %v", err)
@@ -106,10 +138,297 @@ func getAddressHandler(c correios.Correios, s storage.Storage, log logrus.FieldL
 	}
 }
 
-func handleCreateAddress(ctx *gin.Context) {
-	return
+func searchAddressHandler(r *resolver, log logrus.FieldLogger) gin.HandlerFunc {
+	const op errors.Op = "handler.handleSearchAddress"
+	return func(ctx *gin.Context) {
+		query := correios.SearchQuery{
+			State:  ctx.Query("state"),
+			City:   ctx.Query("city"),
+			Street: ctx.Query("street"),
+		}
+		if err := query.Normalize(); err != nil {
+			abortWithError(ctx, errors.E(op, err), nil)
+			return
+		}
+
+		result, err := r.search(ctx.Request.Context(), query)
+		if err != nil {
+			log.Errorf("failed to search addresses: %v", err)
+			abortWithError(ctx, err, nil)
+			return
+		}
+
+		if result == nil {
+			result = []*storage.Address{}
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+type NumberResponse struct {
+	CEP     string           `json:"cep"`
+	Number  int              `json:"number"`
+	InRange bool             `json:"in_range"`
+	Address *storage.Address `json:"address"`
+}
+
+// matchNumber finds the address covering the house number n. A CEP split
+// into several streets or ranges is matched against each of its children.
+func matchNumber(address *storage.Address, n int) (*storage.Address, bool) {
+	if len(address.Children) == 0 {
+		return address, address.NumberRange == nil || address.NumberRange.Contains(n)
+	}
+
+	for _, child := range address.Children {
+		if child.NumberRange != nil && child.NumberRange.Contains(n) {
+			return child, true
+		}
+	}
+
+	return address, false
+}
+
+func numberHandler(r *resolver, log logrus.FieldLogger) gin.HandlerFunc {
+	const op errors.Op = "handler.handleNumber"
+	return func(ctx *gin.Context) {
+		code, err := cep.Parse(ctx.Param("cep"))
+		if err != nil {
+			abortWithError(ctx, errors.E(op, err), nil)
+			return
+		}
+
+		number, err := strconv.Atoi(ctx.Param("number"))
+		if err != nil || number < 1 {
+			abortWithError(ctx, errors.E(op, errors.KindBadRequest, "invalid number, must be a positive integer"), nil)
+			return
+		}
+
+		address, err := r.resolve(ctx.Request.Context(), code)
+		switch {
+		case err == nil:
+		case errors.Is(err, errors.KindNotFound):
+			abortWithError(ctx, errors.E(op, errors.KindNotFound, "address not found"), fmt.Sprintf("CEP %s not found", code.Format()))
+			return
+		default:
+			log.Errorf("failed to get addresses: %v", err)
+			abortWithError(ctx, err, nil)
+			return
+		}
+
+		matched, ok := matchNumber(address, number)
+		ctx.JSON(http.StatusOK, &NumberResponse{
+			CEP:     code.String(),
+			Number:  number,
+			InRange: ok,
+			Address: matched,
+		})
+	}
+}
+
+// warnStateMismatch logs addresses whose state contradicts the range their
+// CEP belongs to.
+func warnStateMismatch(log logrus.FieldLogger, address *storage.Address) {
+	if address == nil || address.State == "" {
+		return
+	}
+
+	code, err := cep.Parse(address.CEP)
+	if err != nil {
+		return
+	}
+
+	if expected, err := cep.StateOf(code); err == nil && !cep.MatchesState(code, address.State) {
+		log.Warnf("cep %s has state %s but belongs to the %s range", code.Format(), address.State, expected)
+	}
 }
 
-func handleUpdateAddress(ctx *gin.Context) {
-	return
+type AddressRequest struct {
+	CEP          string             `json:"cep" binding:"required"`
+	State        string             `json:"state" binding:"required,len=2,alpha"`
+	City         string             `json:"city" binding:"required"`
+	Neighborhood string             `json:"neighborhood"`
+	Location     string             `json:"location"`
+	Children     []*storage.Address `json:"children"`
+	Type         storage.CEPType    `json:"type"`
+	Unit         string             `json:"unit"`
+	Complement   string             `json:"complement"`
+	Subordinate  string             `json:"subordinate"`
+	Status       string             `json:"status"`
+}
+
+// normalize rewrites the CEP in its canonical form, rejecting invalid CEPs
+// and types.
+func (r *AddressRequest) normalize() error {
+	c, err := cep.Parse(r.CEP)
+	if err != nil {
+		return err
+	}
+
+	r.CEP = c.String()
+
+	_, err = storage.ParseCEPType(r.Type.String())
+	return err
+}
+
+func (r *AddressRequest) apply(address *storage.Address) {
+	address.CEP = r.CEP
+	address.State = strings.ToUpper(r.State)
+	address.City = r.City
+	address.Neighborhood = r.Neighborhood
+	address.Location = r.Location
+	address.Children = r.Children
+	address.Type = r.Type
+	address.Unit = r.Unit
+	address.Complement = r.Complement
+	address.Subordinate = r.Subordinate
+	address.Status = r.Status
+}
+
+// PatchAddressRequest holds the fields of a partial update. Fields left out
+// of the payload keep their stored values.
+type PatchAddressRequest struct {
+	CEP          *string             `json:"cep" binding:"omitempty,min=1"`
+	State        *string             `json:"state" binding:"omitempty,len=2,alpha"`
+	City         *string             `json:"city" binding:"omitempty,min=1"`
+	Neighborhood *string             `json:"neighborhood"`
+	Location     *string             `json:"location"`
+	Children     *[]*storage.Address `json:"children"`
+	Type         *storage.CEPType    `json:"type"`
+	Unit         *string             `json:"unit"`
+	Complement   *string             `json:"complement"`
+	Subordinate  *string             `json:"subordinate"`
+	Status       *string             `json:"status"`
+}
+
+// normalize rewrites the CEP, when present, in its canonical form,
+// rejecting invalid CEPs and types.
+func (r *PatchAddressRequest) normalize() error {
+	if r.Type != nil {
+		if _, err := storage.ParseCEPType(r.Type.String()); err != nil {
+			return err
+		}
+	}
+
+	if r.CEP == nil {
+		return nil
+	}
+
+	c, err := cep.Parse(*r.CEP)
+	if err != nil {
+		return err
+	}
+
+	value := c.String()
+	r.CEP = &value
+	return nil
+}
+
+func (r *PatchAddressRequest) apply(address *storage.Address) {
+	if r.CEP != nil {
+		address.CEP = *r.CEP
+	}
+	if r.State != nil {
+		address.State = strings.ToUpper(*r.State)
+	}
+	if r.City != nil {
+		address.City = *r.City
+	}
+	if r.Neighborhood != nil {
+		address.Neighborhood = *r.Neighborhood
+	}
+	if r.Location != nil {
+		address.Location = *r.Location
+	}
+	if r.Children != nil {
+		address.Children = *r.Children
+	}
+	if r.Type != nil {
+		address.Type = *r.Type
+	}
+	if r.Unit != nil {
+		address.Unit = *r.Unit
+	}
+	if r.Complement != nil {
+		address.Complement = *r.Complement
+	}
+	if r.Subordinate != nil {
+		address.Subordinate = *r.Subordinate
+	}
+	if r.Status != nil {
+		address.Status = *r.Status
+	}
+}
+
+func createAddressHandler(s storage.Storage, log logrus.FieldLogger) gin.HandlerFunc {
+	const op errors.Op = "handler.handleCreateAddress"
+	return func(ctx *gin.Context) {
+		var form AddressRequest
+		if err := ctx.ShouldBindJSON(&form); err != nil {
+			abortWithError(ctx, errors.E(op, errors.KindBadRequest, err), nil)
+			return
+		}
+
+		if err := form.normalize(); err != nil {
+			abortWithError(ctx, errors.E(op, err), nil)
+			return
+		}
+
+		address := new(storage.Address)
+		form.apply(address)
+		warnStateMismatch(log, address)
+		if err := s.CreateAddress(ctx, address); err != nil {
+			log.Errorf("failed to create address: %v", err)
+			abortWithError(ctx, err, nil)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, address)
+	}
+}
+
+func updateAddressHandler(s storage.Storage, log logrus.FieldLogger, partial bool) gin.HandlerFunc {
+	const op errors.Op = "handler.handleUpdateAddress"
+	return func(ctx *gin.Context) {
+		code, err := cep.Parse(ctx.Param("cep"))
+		if err != nil {
+			abortWithError(ctx, errors.E(op, err), nil)
+			return
+		}
+
+		var form interface {
+			normalize() error
+			apply(address *storage.Address)
+		}
+		if partial {
+			form = new(PatchAddressRequest)
+		} else {
+			form = new(AddressRequest)
+		}
+
+		if err := ctx.ShouldBindJSON(form); err != nil {
+			abortWithError(ctx, errors.E(op, errors.KindBadRequest, err), nil)
+			return
+		}
+
+		if err := form.normalize(); err != nil {
+			abortWithError(ctx, errors.E(op, err), nil)
+			return
+		}
+
+		var updated *storage.Address
+		updater := func(old *storage.Address) (*storage.Address, error) {
+			form.apply(old)
+			updated = old
+			return old, nil
+		}
+
+		if err := s.UpdateAddress(ctx, code.String(), updater); err != nil {
+			log.Errorf("failed to update address: %v", err)
+			abortWithError(ctx, err, nil)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, updated)
+	}
 }
@@ -0,0 +1,574 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gosundheit "github.com/AppsFlyer/go-sundheit"
+	"github.com/gin-gonic/gin"
+	"github.com/insighted4/correios-cep/correios"
+	"github.com/insighted4/correios-cep/pkg/app"
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/insighted4/correios-cep/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "secret"
+
+type fakeCorreios struct {
+	mu        sync.Mutex
+	addresses map[string]*storage.Address
+	calls     int
+	delay     time.Duration
+}
+
+func (f *fakeCorreios) Check(ctx context.Context) error {
+	return nil
+}
+
+func (f *fakeCorreios) Lookup(ctx context.Context, cep string) (*storage.Address, error) {
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	address, ok := f.addresses[cep]
+	if !ok {
+		return nil, errors.E("fakeCorreios.Lookup", errors.KindNotFound, "not found")
+	}
+
+	clone := *address
+	return &clone, nil
+}
+
+func (f *fakeCorreios) Search(ctx context.Context, query correios.SearchQuery) ([]*storage.Address, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	var addresses []*storage.Address
+	for _, address := range f.addresses {
+		if address.State == query.State && strings.EqualFold(address.City, query.City) &&
+			strings.Contains(strings.ToLower(address.Location), strings.ToLower(query.Street)) {
+			clone := *address
+			addresses = append(addresses, &clone)
+		}
+	}
+
+	return addresses, nil
+}
+
+func (f *fakeCorreios) Set(address *storage.Address) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.addresses[address.CEP] = address
+}
+
+func (f *fakeCorreios) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls
+}
+
+func newTestHandler(t *testing.T) (http.Handler, *memory.Memory, *fakeCorreios) {
+	return newTestHandlerWithConfig(t, Config{
+		NotFoundTTL: time.Hour,
+		Now:         app.StartDate,
+	})
+}
+
+// newTestHandlerWithConfig completes cfg with a memory store, a fake upstream
+// and the test token.
+func newTestHandlerWithConfig(t *testing.T, cfg Config) (http.Handler, *memory.Memory, *fakeCorreios) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	s := memory.New(cfg.Now)
+	c := &fakeCorreios{addresses: map[string]*storage.Address{
+		"74323240": {CEP: "74323240", State: "GO", City: "Goiânia", Neighborhood: "Vila Mauá", Location: "Avenida General Couto de Magalhães"},
+	}}
+
+	cfg.Correios = c
+	cfg.Storage = s
+	cfg.Health = gosundheit.New()
+	cfg.APIToken = testToken
+
+	return New(cfg), s, c
+}
+
+func doRequest(h http.Handler, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set(HeaderAuthorization, "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetAddress(t *testing.T) {
+	h, s, c := newTestHandler(t)
+
+	w := doRequest(h, http.MethodGet, Prefix+"/addresses/74323240", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 1, c.Calls())
+
+	address, err := s.GetAddress(context.Background(), "74323240")
+	require.NoError(t, err)
+	assert.Equal(t, "Vila Mauá", address.Neighborhood)
+
+	w = doRequest(h, http.MethodGet, Prefix+"/addresses/74323240", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 1, c.Calls())
+}
+
+func TestGetAddressNotFound(t *testing.T) {
+	h, s, c := newTestHandler(t)
+
+	for i := 0; i < 3; i++ {
+		w := doRequest(h, http.MethodGet, Prefix+"/addresses/74323241", "", nil)
+		require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	}
+	assert.Equal(t, 1, c.Calls())
+
+	notFound, err := s.GetNotFound(context.Background(), "74323241")
+	require.NoError(t, err)
+	assert.Equal(t, app.StartDate().Add(time.Hour), notFound.ExpiresAt)
+}
+
+func TestGetAddressOutsideStateRanges(t *testing.T) {
+	h, s, c := newTestHandler(t)
+
+	w := doRequest(h, http.MethodGet, Prefix+"/addresses/00100000", "", nil)
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	require.NoError(t, s.CreateAddress(context.Background(), &storage.Address{CEP: "00100000", State: "SP"}))
+
+	w = doRequest(h, http.MethodGet, Prefix+"/addresses/00100000", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 0, c.Calls())
+}
+
+func TestSearchAddress(t *testing.T) {
+	h, s, c := newTestHandler(t)
+
+	query := url.Values{"state": {"go"}, "city": {"goiânia"}, "street": {"couto de"}}
+	w := doRequest(h, http.MethodGet, Prefix+"/addresses/search?"+query.Encode(), "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var addresses []*storage.Address
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &addresses))
+	require.Len(t, addresses, 1)
+	assert.Equal(t, "74323240", addresses[0].CEP)
+
+	// Searched addresses are cached for lookups.
+	_, err := s.GetAddress(context.Background(), "74323240")
+	require.NoError(t, err)
+
+	w = doRequest(h, http.MethodGet, Prefix+"/addresses/74323240", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 1, c.Calls())
+
+	// Repeating the search, whatever the case and accents, is answered from
+	// storage.
+	query = url.Values{"state": {"GO"}, "city": {"Goiania"}, "street": {"Couto De"}}
+	w = doRequest(h, http.MethodGet, Prefix+"/addresses/search?"+query.Encode(), "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &addresses))
+	require.Len(t, addresses, 1)
+	assert.Equal(t, "74323240", addresses[0].CEP)
+	assert.Equal(t, 1, c.Calls())
+
+	query.Set("street", "rua inexistente")
+	for i := 0; i < 2; i++ {
+		w = doRequest(h, http.MethodGet, Prefix+"/addresses/search?"+query.Encode(), "", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, "[]", w.Body.String())
+	}
+	assert.Equal(t, 2, c.Calls())
+}
+
+func TestAutocomplete(t *testing.T) {
+	h, s, c := newTestHandler(t)
+
+	ctx := context.Background()
+	require.NoError(t, s.CreateAddress(ctx, &storage.Address{CEP: "74323240", State: "GO", City: "Goiânia", Neighborhood: "Vila Mauá", Location: "Avenida General Couto de Magalhães"}))
+	require.NoError(t, s.CreateAddress(ctx, &storage.Address{CEP: "74001970", State: "GO", City: "Goiânia", Neighborhood: "Setor Central", Location: "Avenida Anhanguera"}))
+	require.NoError(t, s.CreateAddress(ctx, &storage.Address{CEP: "01003900", State: "SP", City: "São Paulo", Neighborhood: "Sé", Location: "Rua Goiânia"}))
+
+	autocomplete := func(query string) []string {
+		w := doRequest(h, http.MethodGet, Prefix+"/addresses/autocomplete?"+query, "", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var addresses []*storage.Address
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &addresses))
+
+		ceps := make([]string, len(addresses))
+		for i, address := range addresses {
+			ceps[i] = address.CEP
+		}
+
+		return ceps
+	}
+
+	assert.ElementsMatch(t, []string{"74323240", "74001970", "01003900"}, autocomplete("q=goiania"))
+	assert.ElementsMatch(t, []string{"74323240", "74001970"}, autocomplete("q=goiania&state=go"))
+	assert.Equal(t, []string{"74323240"}, autocomplete("q=goiania+maua"))
+	assert.Len(t, autocomplete("q=goiania&limit=1"), 1)
+	assert.Empty(t, autocomplete("q=recife"))
+
+	w := doRequest(h, http.MethodGet, Prefix+"/addresses/autocomplete?q=go", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	assert.Equal(t, 0, c.Calls())
+}
+
+func TestSearchAddressInvalid(t *testing.T) {
+	h, _, c := newTestHandler(t)
+
+	for _, query := range []string{
+		"city=Goi%C3%A2nia&street=Avenida",
+		"state=GOI&city=Goi%C3%A2nia&street=Avenida",
+		"state=GO&street=Avenida",
+		"state=GO&city=Goi%C3%A2nia&street=Av",
+	} {
+		w := doRequest(h, http.MethodGet, Prefix+"/addresses/search?"+query, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	assert.Equal(t, 0, c.Calls())
+}
+
+func TestGetAddressConcurrent(t *testing.T) {
+	h, _, c := newTestHandler(t)
+	c.delay = 50 * time.Millisecond
+
+	const n = 50
+	codes := make(chan int, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- doRequest(h, http.MethodGet, Prefix+"/addresses/74323240", "", nil).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	for code := range codes {
+		assert.Equal(t, http.StatusOK, code)
+	}
+	assert.Equal(t, 1, c.Calls())
+}
+
+// clock is a manually advanced time source.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func TestGetAddressStale(t *testing.T) {
+	clk := &clock{now: app.StartDate()}
+	h, _, c := newTestHandlerWithConfig(t, Config{MaxAge: time.Hour, Now: clk.Now})
+
+	w := doRequest(h, http.MethodGet, Prefix+"/addresses/74323240", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	c.Set(&storage.Address{CEP: "74323240", State: "GO", City: "Goiânia", Neighborhood: "Setor Sul", Location: "Avenida General Couto de Magalhães"})
+
+	clk.Advance(30 * time.Minute)
+	w = doRequest(h, http.MethodGet, Prefix+"/addresses/74323240", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Vila Mauá")
+	assert.Equal(t, 1, c.Calls())
+
+	clk.Advance(time.Hour)
+	w = doRequest(h, http.MethodGet, Prefix+"/addresses/74323240", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Setor Sul")
+	assert.Equal(t, 2, c.Calls())
+}
+
+func TestGetAddressStaleWhileRevalidate(t *testing.T) {
+	clk := &clock{now: app.StartDate()}
+	h, s, c := newTestHandlerWithConfig(t, Config{MaxAge: time.Hour, StaleWhileRevalidate: true, Now: clk.Now})
+
+	w := doRequest(h, http.MethodGet, Prefix+"/addresses/74323240", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	c.Set(&storage.Address{CEP: "74323240", State: "GO", City: "Goiânia", Neighborhood: "Setor Sul", Location: "Avenida General Couto de Magalhães"})
+
+	clk.Advance(2 * time.Hour)
+	w = doRequest(h, http.MethodGet, Prefix+"/addresses/74323240", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Vila Mauá")
+
+	assert.Eventually(t, func() bool {
+		address, err := s.GetAddress(context.Background(), "74323240")
+		return err == nil && address.Neighborhood == "Setor Sul"
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, c.Calls())
+}
+
+func TestGetAddressNormalizesCEP(t *testing.T) {
+	h, _, c := newTestHandler(t)
+
+	for _, input := range []string{"74323-240", "74323240", "%2074323240"} {
+		w := doRequest(h, http.MethodGet, Prefix+"/addresses/"+input, "", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+	assert.Equal(t, 1, c.Calls())
+
+	for _, input := range []string{"abc", "7432324", "00000-000"} {
+		w := doRequest(h, http.MethodGet, Prefix+"/addresses/"+input, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, input)
+	}
+	assert.Equal(t, 1, c.Calls())
+
+	w := doRequest(h, http.MethodGet, Prefix+"/addresses/00999-999", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, 1, c.Calls())
+}
+
+func TestCEPState(t *testing.T) {
+	h, _, c := newTestHandler(t)
+
+	w := doRequest(h, http.MethodGet, Prefix+"/ceps/74323-240/state", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"cep": "74323240", "state": "GO"}`, w.Body.String())
+
+	w = doRequest(h, http.MethodGet, Prefix+"/ceps/00999999/state", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = doRequest(h, http.MethodGet, Prefix+"/ceps/abc/state", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 0, c.Calls())
+}
+
+func TestCreateAddress(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+
+	body := map[string]interface{}{
+		"cep":          "74001-970",
+		"state":        "go",
+		"city":         "Goiânia",
+		"neighborhood": "Setor Central",
+		"location":     "Praça Doutor Pedro Ludovico Teixeira, 11",
+	}
+
+	w := doRequest(h, http.MethodPost, Prefix+"/addresses", "", body)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doRequest(h, http.MethodPost, Prefix+"/addresses", "wrong", body)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doRequest(h, http.MethodPost, Prefix+"/addresses", testToken, body)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	address, err := s.GetAddress(context.Background(), "74001970")
+	require.NoError(t, err)
+	assert.Equal(t, "GO", address.State)
+
+	w = doRequest(h, http.MethodPost, Prefix+"/addresses", testToken, body)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = doRequest(h, http.MethodPost, Prefix+"/addresses", testToken, map[string]string{"cep": "74001970"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	body["cep"] = "7400197"
+	w = doRequest(h, http.MethodPost, Prefix+"/addresses", testToken, body)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateAddress(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.CreateAddress(ctx, &storage.Address{CEP: "74003010", State: "GO", City: "Goiania", Neighborhood: "Setor Central"}))
+
+	body := map[string]interface{}{
+		"cep":   "74003010",
+		"state": "GO",
+		"city":  "Goiânia",
+	}
+
+	w := doRequest(h, http.MethodPut, Prefix+"/addresses/74003010", "", body)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doRequest(h, http.MethodPut, Prefix+"/addresses/74003010", testToken, body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	address, err := s.GetAddress(ctx, "74003010")
+	require.NoError(t, err)
+	assert.Equal(t, "Goiânia", address.City)
+	assert.Empty(t, address.Neighborhood)
+
+	w = doRequest(h, http.MethodPatch, Prefix+"/addresses/74003010", testToken, map[string]string{"neighborhood": "Setor Central"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	address, err = s.GetAddress(ctx, "74003010")
+	require.NoError(t, err)
+	assert.Equal(t, "Goiânia", address.City)
+	assert.Equal(t, "Setor Central", address.Neighborhood)
+
+	w = doRequest(h, http.MethodPatch, Prefix+"/addresses/74003010", testToken, map[string]string{"state": "Goiás"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(h, http.MethodPatch, Prefix+"/addresses/74003-010", testToken, map[string]string{"cep": "invalid"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(h, http.MethodPatch, Prefix+"/addresses/74000000", testToken, map[string]string{"city": "Goiânia"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestListAddressesByType(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.CreateAddress(ctx, &storage.Address{CEP: "74001970", State: "GO", Type: storage.CEPTypeUnit}))
+	require.NoError(t, s.CreateAddress(ctx, &storage.Address{CEP: "74003010", State: "GO", Type: storage.CEPTypeStreet}))
+
+	w := doRequest(h, http.MethodGet, Prefix+"/addresses?state=GO&type=unidade_operacional", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var addresses []*storage.Address
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &addresses))
+	require.Len(t, addresses, 1)
+	assert.Equal(t, "74001970", addresses[0].CEP)
+
+	w = doRequest(h, http.MethodGet, Prefix+"/addresses?state=GO&type=5", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(h, http.MethodGet, Prefix+"/addresses?state=GO&page=-1", "", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &addresses))
+	assert.Len(t, addresses, 2)
+}
+
+func TestNumber(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.CreateAddress(ctx, &storage.Address{
+		CEP:         "74003010",
+		State:       "GO",
+		Street:      "Rua X",
+		NumberRange: &storage.NumberRange{Side: storage.SideBoth, To: 1000},
+	}))
+	require.NoError(t, s.CreateAddress(ctx, &storage.Address{
+		CEP:   "74691550",
+		State: "GO",
+		Children: []*storage.Address{
+			{CEP: "74691551", Street: "Avenida Y", NumberRange: &storage.NumberRange{Side: storage.SideOdd, From: 1001}},
+			{CEP: "74691552", Street: "Avenida Y", NumberRange: &storage.NumberRange{Side: storage.SideEven, From: 1002}},
+		},
+	}))
+
+	tests := []struct {
+		path    string
+		inRange bool
+		cep     string
+	}{
+		{"/addresses/74003010/numbers/999", true, "74003010"},
+		{"/addresses/74003010/numbers/1001", false, "74003010"},
+		{"/addresses/74691550/numbers/1003", true, "74691551"},
+		{"/addresses/74691550/numbers/1004", true, "74691552"},
+		{"/addresses/74691550/numbers/10", false, "74691550"},
+		{"/addresses/74323240/numbers/10", true, "74323240"},
+	}
+
+	for _, tt := range tests {
+		w := doRequest(h, http.MethodGet, Prefix+tt.path, "", nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response NumberResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, tt.inRange, response.InRange, tt.path)
+		assert.Equal(t, tt.cep, response.Address.CEP, tt.path)
+	}
+
+	w := doRequest(h, http.MethodGet, Prefix+"/addresses/74003010/numbers/abc", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(h, http.MethodGet, Prefix+"/addresses/74003010/numbers/0", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestExportAddress(t *testing.T) {
+	h, s, _ := newTestHandler(t)
+
+	ctx := context.Background()
+	require.NoError(t, s.CreateAddress(ctx, &storage.Address{CEP: "74323240", State: "GO", City: "Goiânia", Location: "Avenida General Couto de Magalhães"}))
+	require.NoError(t, s.CreateAddress(ctx, &storage.Address{CEP: "74001970", State: "GO", City: "Goiânia", Location: "Avenida Anhanguera"}))
+	require.NoError(t, s.CreateAddress(ctx, &storage.Address{CEP: "01003900", State: "SP", City: "São Paulo", Location: "Rua Goiânia"}))
+
+	w := doRequest(h, http.MethodGet, Prefix+"/addresses/export", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doRequest(h, http.MethodGet, Prefix+"/addresses/export?state=go", testToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="addresses.csv"`, w.Header().Get("Content-Disposition"))
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "cep,state,city"), lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "74001970,GO,Goiânia"), lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "74323240,GO,Goiânia"), lines[2])
+
+	w = doRequest(h, http.MethodGet, Prefix+"/addresses/export?format=ndjson", testToken, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Len(t, strings.Split(strings.TrimSpace(w.Body.String()), "\n"), 3)
+
+	w = doRequest(h, http.MethodGet, Prefix+"/addresses/export?format=xml", testToken, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+}
@@ -0,0 +1,52 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/insighted4/correios-cep/cep"
+	"github.com/insighted4/correios-cep/pkg/errors"
+)
+
+type CEPStateResponse struct {
+	CEP   string `json:"cep"`
+	State string `json:"state"`
+}
+
+// cepStateHandler infers the state of a CEP from the official ranges,
+// without touching storage or upstream.
+func cepStateHandler() gin.HandlerFunc {
+	const op errors.Op = "handler.handleCEPState"
+	return func(ctx *gin.Context) {
+		code, err := cep.Parse(ctx.Param("cep"))
+		if err != nil {
+			abortWithError(ctx, errors.E(op, err), nil)
+			return
+		}
+
+		state, err := cep.StateOf(code)
+		if err != nil {
+			abortWithError(ctx, errors.E(op, err), nil)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, &CEPStateResponse{
+			CEP:   code.String(),
+			State: state,
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"fmt"
+	"iter"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/insighted4/correios-cep/export"
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/sirupsen/logrus"
+)
+
+func exportAddressHandler(s storage.Storage, log logrus.FieldLogger) gin.HandlerFunc {
+	type ExportRequest struct {
+		Format string `json:"format" form:"format"`
+		State  string `json:"state" form:"state"`
+	}
+
+	const op errors.Op = "handler.handleExportAddress"
+	return func(ctx *gin.Context) {
+		var form ExportRequest
+		if err := ctx.ShouldBind(&form); err != nil {
+			abortWithError(ctx, errors.E(op, errors.KindBadRequest, err), nil)
+			return
+		}
+
+		if form.Format == "" {
+			form.Format = export.FormatCSV.String()
+		}
+
+		format, err := export.ParseFormat(form.Format)
+		if err != nil {
+			abortWithError(ctx, errors.E(op, err), nil)
+			return
+		}
+
+		// Failing to read the first address still gets an error response,
+		// while later failures can only cut the stream short.
+		addresses, stop := peek(s.ExportAddresses(ctx, storage.ExportParams{State: form.State}))
+		defer stop()
+
+		if _, err, ok := addresses.first(); ok && err != nil {
+			log.Errorf("failed to export addresses: %v", err)
+			abortWithError(ctx, err, nil)
+			return
+		}
+
+		// Exports outlast the write timeout of the server.
+		_ = http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{})
+
+		ctx.Header("Content-Type", format.ContentType())
+		ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="addresses.%s"`, format))
+		ctx.Status(http.StatusOK)
+
+		n, err := export.Write(ctx.Writer, format, addresses.all)
+		if err != nil {
+			log.Errorf("failed to export addresses after %d: %v", n, err)
+			_ = ctx.Error(err)
+			return
+		}
+
+		log.Infof("Exported %d addresses", n)
+	}
+}
+
+// peeked is an iterator whose first element was read ahead.
+type peeked struct {
+	next func() (*storage.Address, error, bool)
+
+	address *storage.Address
+	err     error
+	ok      bool
+}
+
+// peek reads the first element of seq ahead. stop must be called to release
+// seq.
+func peek(seq iter.Seq2[*storage.Address, error]) (*peeked, func()) {
+	next, stop := iter.Pull2(seq)
+	p := &peeked{next: next}
+	p.address, p.err, p.ok = next()
+
+	return p, stop
+}
+
+func (p *peeked) first() (*storage.Address, error, bool) {
+	return p.address, p.err, p.ok
+}
+
+// all yields the first element, then the rest of the iterator.
+func (p *peeked) all(yield func(*storage.Address, error) bool) {
+	for address, err, ok := p.first(); ok; address, err, ok = p.next() {
+		if !yield(address, err) {
+			return
+		}
+	}
+}
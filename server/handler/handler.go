@@ -36,26 +36,76 @@ import (
 
 const Prefix = "/api/v1"
 
-func New(correios correios.Correios, storage storage.Storage, health gosundheit.Health, release bool, now func() time.Time) http.Handler {
-	if release {
+type Config struct {
+	Correios correios.Correios
+	Storage  storage.Storage
+	Health   gosundheit.Health
+
+	// APIToken authorizes requests that change data. When empty, those
+	// requests are always rejected.
+	APIToken string
+
+	// NotFoundTTL is how long CEPs unknown upstream are answered as not
+	// found without another lookup. Zero disables negative caching.
+	NotFoundTTL time.Duration
+
+	// MaxAge is how long a cached address is served before it is refreshed
+	// from upstream. Zero serves cached addresses forever.
+	MaxAge time.Duration
+
+	// StaleWhileRevalidate serves stale addresses immediately and refreshes
+	// them in the background.
+	StaleWhileRevalidate bool
+
+	// Set gin mode to release.
+	ReleaseMode bool
+
+	// If specified, the handler will use this function for determining time.
+	Now func() time.Time
+}
+
+func New(cfg Config) http.Handler {
+	if cfg.ReleaseMode {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+
 	logger := log.WithField("component", "handler")
+	if cfg.APIToken == "" {
+		logger.Warn("No API token configured: write and export endpoints are disabled")
+	}
 
 	router := gin.New()
+	// Handlers pass the gin context down, which must be canceled with the
+	// request so upstream calls are abandoned when clients go away.
+	router.ContextWithFallback = true
 	router.Use(gin.Recovery())
 	router.Use(cors.Default())
-	router.Use(LoggerMiddleware(logger, now, time.RFC3339, true))
+	router.Use(LoggerMiddleware(logger, cfg.Now, time.RFC3339, true))
 	router.NoRoute(notFoundHandler())
 
 	router.GET("/", rootHandler())
-	router.GET("/health", healthHandler(health, logger))
+	router.GET("/health", healthHandler(cfg.Health, logger))
 	router.GET("/ping", pingHandler())
 
+	resolver := newResolver(cfg, logger)
+
 	api := router.Group(Prefix)
-	api.GET("/addresses", listAddressHandler(storage, logger))
-	api.GET("/addresses/:cep", getAddressHandler(correios, storage, logger))
+	api.GET("/addresses", listAddressHandler(cfg.Storage, logger))
+	api.GET("/addresses/autocomplete", autocompleteHandler(cfg.Storage, logger))
+	api.GET("/addresses/search", searchAddressHandler(resolver, logger))
+	api.GET("/addresses/:cep", getAddressHandler(resolver, logger))
+	api.GET("/addresses/:cep/numbers/:number", numberHandler(resolver, logger))
+	api.GET("/ceps/:cep/state", cepStateHandler())
+
+	admin := api.Group("", AuthMiddleware(cfg.APIToken))
+	admin.POST("/addresses", createAddressHandler(cfg.Storage, logger))
+	admin.PUT("/addresses/:cep", updateAddressHandler(cfg.Storage, logger, false))
+	admin.PATCH("/addresses/:cep", updateAddressHandler(cfg.Storage, logger, true))
+	admin.GET("/addresses/export", exportAddressHandler(cfg.Storage, logger))
 
 	return router
 }
@@ -127,7 +177,7 @@ func abortWithError(ctx *gin.Context, err error, details interface{}) {
 	var typedError errors.Error
 	switch {
 	case errors.AsErr(err, &typedError):
-		code = typedError.Kind
+		code = errors.Kind(typedError)
 		msg = newLine.ReplaceAllString(typedError.Error(), " ")
 		if index := strings.Index(msg, ":"); len(msg) > index+1 {
 			msg = strings.TrimSpace(msg[index+1:])
@@ -15,13 +15,19 @@
 package handler
 
 import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
-const HeaderRequestID = "X-Request-Id"
+const (
+	HeaderAuthorization = "Authorization"
+	HeaderRequestID     = "X-Request-Id"
+)
 
 // LoggerMiddleware returns a gin.HandlerFunc (middleware) that logs requests using logrus.
 //
@@ -65,3 +71,19 @@ func LoggerMiddleware(logger logrus.FieldLogger, now func() time.Time, timeForma
 		}
 	}
 }
+
+// AuthMiddleware returns a gin.HandlerFunc (middleware) that only lets through
+// requests carrying the given token as "Authorization: Bearer <token>".
+// Every request is rejected when token is empty.
+func AuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided, ok := strings.CutPrefix(c.GetHeader(HeaderAuthorization), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", "Bearer")
+			abortWithStatus(c, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), nil)
+			return
+		}
+
+		c.Next()
+	}
+}
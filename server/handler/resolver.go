@@ -0,0 +1,309 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/insighted4/correios-cep/cep"
+	"github.com/insighted4/correios-cep/correios"
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/refresh"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// upstreamTimeout bounds upstream lookups that outlive the request that
+// triggered them.
+const upstreamTimeout = time.Minute
+
+// resolver finds addresses in storage, looking them up upstream and caching
+// them on a miss. CEPs unknown upstream are remembered for notFoundTTL, or
+// not at all when it is zero. Addresses older than maxAge are refreshed
+// before being served, or in the background when staleWhileRevalidate is
+// set.
+type resolver struct {
+	correios             correios.Correios
+	storage              storage.Storage
+	notFoundTTL          time.Duration
+	maxAge               time.Duration
+	staleWhileRevalidate bool
+	now                  func() time.Time
+	logger               logrus.FieldLogger
+
+	// calls holds the upstream lookups in flight, by CEP.
+	mu    sync.Mutex
+	calls map[string]*call
+
+	// refreshing holds the CEPs being refreshed in the background.
+	refreshing sync.Map
+}
+
+func newResolver(cfg Config, logger logrus.FieldLogger) *resolver {
+	return &resolver{
+		correios:             cfg.Correios,
+		storage:              cfg.Storage,
+		notFoundTTL:          cfg.NotFoundTTL,
+		maxAge:               cfg.MaxAge,
+		staleWhileRevalidate: cfg.StaleWhileRevalidate,
+		now:                  cfg.Now,
+		logger:               logger,
+		calls:                make(map[string]*call),
+	}
+}
+
+func (r *resolver) resolve(ctx context.Context, code cep.CEP) (*storage.Address, error) {
+	const op errors.Op = "handler.resolve"
+
+	addr, err := r.storage.GetAddress(ctx, code.String())
+	if err == nil {
+		return r.revalidate(ctx, addr), nil
+	}
+
+	if errors.Is(err, errors.KindUnexpected) {
+		return nil, err
+	}
+
+	// CEPs outside every state range cannot exist upstream, though they may
+	// have been stored through the API.
+	if _, err := cep.StateOf(code); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	if r.notFoundTTL > 0 {
+		if _, err := r.storage.GetNotFound(ctx, code.String()); err == nil {
+			return nil, errors.E(op, errors.KindNotFound, fmt.Sprintf("cep %s not found", code))
+		} else if errors.Is(err, errors.KindUnexpected) {
+			return nil, err
+		}
+	}
+
+	return r.lookup(ctx, code)
+}
+
+// call is an upstream lookup shared by the requests waiting on it.
+type call struct {
+	done    chan struct{}
+	address *storage.Address
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// lookup resolves a cache miss upstream. Concurrent misses for the same CEP
+// share a single lookup, which runs on its own context rather than the one of
+// the request that started it, and is only canceled once every request
+// waiting on it has gone away.
+func (r *resolver) lookup(ctx context.Context, code cep.CEP) (*storage.Address, error) {
+	const op errors.Op = "handler.lookup"
+
+	key := code.String()
+
+	r.mu.Lock()
+	c, ok := r.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+		c = &call{done: make(chan struct{}), cancel: cancel}
+		r.calls[key] = c
+
+		go func() {
+			defer cancel()
+
+			c.address, c.err = r.fetch(callCtx, code)
+
+			r.mu.Lock()
+			if r.calls[key] == c {
+				delete(r.calls, key)
+			}
+			r.mu.Unlock()
+			close(c.done)
+		}()
+	}
+	c.waiters++
+	r.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.address, c.err
+	case <-ctx.Done():
+		r.mu.Lock()
+		if c.waiters--; c.waiters == 0 {
+			c.cancel()
+			if r.calls[key] == c {
+				delete(r.calls, key)
+			}
+		}
+		r.mu.Unlock()
+
+		return nil, errors.E(op, errors.KindUnexpected, ctx.Err())
+	}
+}
+
+func (r *resolver) fetch(ctx context.Context, code cep.CEP) (*storage.Address, error) {
+	const op errors.Op = "handler.fetch"
+
+	// A previous lookup may have stored it since the caller missed.
+	if addr, err := r.storage.GetAddress(ctx, code.String()); err == nil {
+		return addr, nil
+	}
+
+	addr, err := r.correios.Lookup(ctx, code.String())
+	if errors.Is(err, errors.KindNotFound) || (err == nil && addr == nil) {
+		r.rememberNotFound(ctx, code)
+		return nil, errors.E(op, errors.KindNotFound, fmt.Sprintf("cep %s not found", code))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	warnStateMismatch(r.logger, addr)
+
+	// Another instance sharing the storage may have stored it first.
+	if err := r.storage.CreateAddress(ctx, addr); err != nil && !errors.Is(err, errors.KindAlreadyExists) {
+		return nil, err
+	}
+
+	return addr, nil
+}
+
+// search finds the addresses of a street, from storage when the same search
+// was made upstream before, or upstream, caching the addresses not stored
+// yet and the CEPs found. Failing to cache only costs another search later,
+// so storage errors are logged and dropped.
+func (r *resolver) search(ctx context.Context, query correios.SearchQuery) ([]*storage.Address, error) {
+	key := searchKey(query)
+	if addresses, ok := r.cachedSearch(ctx, key); ok {
+		return addresses, nil
+	}
+
+	addresses, err := r.correios.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	ceps := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		warnStateMismatch(r.logger, addr)
+
+		if err := r.storage.CreateAddress(ctx, addr); err != nil && !errors.Is(err, errors.KindAlreadyExists) {
+			r.logger.WithError(err).WithField("cep", addr.CEP).Warn("unable to cache searched address")
+		}
+		ceps = append(ceps, addr.CEP)
+	}
+
+	if err := r.storage.CreateSearchResult(ctx, &storage.SearchResult{Key: key, CEPs: ceps}); err != nil {
+		r.logger.WithError(err).WithField("search", key).Warn("unable to cache search result")
+	}
+
+	return addresses, nil
+}
+
+// cachedSearch returns the stored addresses of a search made upstream
+// before. Searches older than maxAge, or with addresses no longer stored,
+// are made again.
+func (r *resolver) cachedSearch(ctx context.Context, key string) ([]*storage.Address, bool) {
+	result, err := r.storage.GetSearchResult(ctx, key)
+	if err != nil {
+		if !errors.Is(err, errors.KindNotFound) {
+			r.logger.WithError(err).WithField("search", key).Warn("unable to read cached search result")
+		}
+		return nil, false
+	}
+
+	if r.maxAge > 0 && result.CreatedAt != nil && r.now().Sub(*result.CreatedAt) > r.maxAge {
+		return nil, false
+	}
+
+	addresses := make([]*storage.Address, 0, len(result.CEPs))
+	for _, code := range result.CEPs {
+		address, err := r.storage.GetAddress(ctx, code)
+		if err != nil {
+			if !errors.Is(err, errors.KindNotFound) {
+				r.logger.WithError(err).WithField("cep", code).Warn("unable to read cached searched address")
+			}
+			return nil, false
+		}
+
+		addresses = append(addresses, address)
+	}
+
+	return addresses, true
+}
+
+// searchKey identifies a search regardless of case and accents.
+func searchKey(query correios.SearchQuery) string {
+	return storage.Fold(query.String())
+}
+
+// rememberNotFound records code as unknown upstream. Failing to do so only
+// costs another upstream lookup, so errors are logged and dropped.
+func (r *resolver) rememberNotFound(ctx context.Context, code cep.CEP) {
+	if r.notFoundTTL <= 0 {
+		return
+	}
+
+	notFound := &storage.NotFound{
+		CEP:       code.String(),
+		ExpiresAt: r.now().Add(r.notFoundTTL),
+	}
+	if err := r.storage.CreateNotFound(ctx, notFound); err != nil {
+		r.logger.WithError(err).WithField("cep", code.String()).Warn("unable to cache not found cep")
+	}
+}
+
+func (r *resolver) stale(address *storage.Address) bool {
+	return r.maxAge > 0 && address.UpdatedAt != nil && r.now().Sub(*address.UpdatedAt) > r.maxAge
+}
+
+// revalidate returns the freshest version of a cached address it can. Stale
+// addresses are served as they are when the refresh fails.
+func (r *resolver) revalidate(ctx context.Context, address *storage.Address) *storage.Address {
+	if !r.stale(address) {
+		return address
+	}
+
+	if r.staleWhileRevalidate {
+		r.refreshInBackground(address.CEP)
+		return address
+	}
+
+	refreshed, err := refresh.Address(ctx, r.correios, r.storage, address.CEP)
+	if err != nil {
+		r.logger.WithError(err).WithField("cep", address.CEP).Warn("serving stale address")
+		return address
+	}
+
+	return refreshed
+}
+
+func (r *resolver) refreshInBackground(cep string) {
+	if _, loaded := r.refreshing.LoadOrStore(cep, struct{}{}); loaded {
+		return
+	}
+
+	go func() {
+		defer r.refreshing.Delete(cep)
+
+		ctx, cancel := context.WithTimeout(context.Background(), upstreamTimeout)
+		defer cancel()
+
+		if _, err := refresh.Address(ctx, r.correios, r.storage, cep); err != nil {
+			r.logger.WithError(err).WithField("cep", cep).Warn("unable to refresh stale address")
+		}
+	}()
+}
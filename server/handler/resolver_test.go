@@ -0,0 +1,168 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/insighted4/correios-cep/cep"
+	"github.com/insighted4/correios-cep/correios"
+	"github.com/insighted4/correios-cep/pkg/app"
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/pkg/log"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/insighted4/correios-cep/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hangingCorreios holds every lookup until its context is done.
+type hangingCorreios struct {
+	started  chan struct{}
+	canceled chan struct{}
+}
+
+func (h *hangingCorreios) Check(ctx context.Context) error {
+	return nil
+}
+
+func (h *hangingCorreios) Lookup(ctx context.Context, cep string) (*storage.Address, error) {
+	close(h.started)
+	<-ctx.Done()
+	close(h.canceled)
+	return nil, errors.E("hangingCorreios.Lookup", errors.KindUnexpected, ctx.Err())
+}
+
+func (h *hangingCorreios) Search(ctx context.Context, query correios.SearchQuery) ([]*storage.Address, error) {
+	<-ctx.Done()
+	return nil, errors.E("hangingCorreios.Search", errors.KindUnexpected, ctx.Err())
+}
+
+func TestResolver_CancelsAbandonedLookup(t *testing.T) {
+	c := &hangingCorreios{started: make(chan struct{}), canceled: make(chan struct{})}
+	r := newResolver(Config{Correios: c, Storage: memory.New(nil), Now: app.StartDate}, log.WithField("component", "test"))
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithCancel(context.Background())
+
+	errs := make(chan error, 2)
+	go func() {
+		_, err := r.resolve(first, cep.MustParse("74323240"))
+		errs <- err
+	}()
+	<-c.started
+	go func() {
+		_, err := r.resolve(second, cep.MustParse("74323240"))
+		errs <- err
+	}()
+
+	// The lookup keeps going while a request still waits on it.
+	assert.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.calls["74323240"] != nil && r.calls["74323240"].waiters == 2
+	}, time.Second, time.Millisecond)
+
+	cancelFirst()
+	assert.Error(t, <-errs)
+	select {
+	case <-c.canceled:
+		t.Fatal("lookup canceled while a request still waits on it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancelSecond()
+	assert.Error(t, <-errs)
+	select {
+	case <-c.canceled:
+	case <-time.After(time.Second):
+		t.Fatal("abandoned lookup was not canceled")
+	}
+}
+
+// gatedCorreios holds every lookup until it is released and reports whether
+// the lookup context was still usable by then.
+type gatedCorreios struct {
+	fakeCorreios
+	started chan struct{}
+	release chan struct{}
+	leaked  chan bool
+}
+
+func (g *gatedCorreios) Lookup(ctx context.Context, cep string) (*storage.Address, error) {
+	close(g.started)
+	<-g.release
+	g.leaked <- ctx.Value(gin.ContextKey) != nil
+	if err := ctx.Err(); err != nil {
+		return nil, errors.E("gatedCorreios.Lookup", errors.KindUnexpected, err)
+	}
+	return g.fakeCorreios.Lookup(ctx, cep)
+}
+
+func TestGetAddress_LookupOutlivesRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := memory.New(app.StartDate)
+	c := &gatedCorreios{
+		fakeCorreios: fakeCorreios{addresses: map[string]*storage.Address{
+			"74323240": {CEP: "74323240", State: "GO", City: "Goiânia"},
+		}},
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+		leaked:  make(chan bool, 1),
+	}
+	logger := log.WithField("component", "test")
+	r := newResolver(Config{Correios: c, Storage: s, Now: app.StartDate}, logger)
+
+	router := gin.New()
+	router.ContextWithFallback = true
+	router.GET("/addresses/:cep", getAddressHandler(r, logger))
+	router.GET("/ping", pingHandler())
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	codes := make(chan int, 2)
+	get := func(ctx context.Context) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/addresses/74323240", nil).WithContext(ctx))
+		codes <- w.Code
+	}
+	go get(first)
+	<-c.started
+	go get(context.Background())
+
+	assert.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.calls["74323240"] != nil && r.calls["74323240"].waiters == 2
+	}, time.Second, time.Millisecond)
+
+	// The request that started the lookup goes away and gin hands its
+	// context over to the next request.
+	cancelFirst()
+	assert.Equal(t, http.StatusInternalServerError, <-codes)
+	assert.Equal(t, http.StatusOK, doRequest(router, http.MethodGet, "/ping", "", nil).Code)
+
+	close(c.release)
+	assert.False(t, <-c.leaked, "lookup must not run on a request context")
+	assert.Equal(t, http.StatusOK, <-codes)
+
+	_, err := s.GetAddress(context.Background(), "74323240")
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.Calls())
+}
@@ -15,6 +15,7 @@
 package server
 
 import (
+	"context"
 	"time"
 
 	gosundheit "github.com/AppsFlyer/go-sundheit"
@@ -26,6 +27,7 @@ import (
 	"github.com/insighted4/correios-cep/pkg/log"
 	"github.com/insighted4/correios-cep/pkg/net"
 	"github.com/insighted4/correios-cep/pkg/version"
+	"github.com/insighted4/correios-cep/refresh"
 	"github.com/insighted4/correios-cep/server/handler"
 	"github.com/insighted4/correios-cep/storage"
 	"github.com/sirupsen/logrus"
@@ -42,8 +44,33 @@ type Config struct {
 	// Set gin mode to release.
 	ReleaseMode bool
 
+	// APIToken authorizes requests that change data.
+	APIToken string
+
+	// NotFoundTTL is how long CEPs unknown upstream are remembered.
+	NotFoundTTL time.Duration
+
+	// MaxAge is how long a cached address is served before it is refreshed.
+	// Zero serves cached addresses forever.
+	MaxAge time.Duration
+
+	// StaleWhileRevalidate serves stale addresses and refreshes them in the
+	// background.
+	StaleWhileRevalidate bool
+
+	// RefreshInterval is the period between sweeps of the oldest addresses.
+	// Zero disables the sweeps.
+	RefreshInterval time.Duration
+
+	// RefreshBatchSize is the number of addresses refreshed per sweep.
+	RefreshBatchSize int
+
 	Storage storage.Storage
 
+	// Correios is the upstream provider used on cache misses. Defaults to
+	// the Correios website when nil.
+	Correios correios.Correios
+
 	// If specified, the server will use this function for determining time.
 	Now func() time.Time
 }
@@ -55,6 +82,8 @@ type Service struct {
 	logger   logrus.FieldLogger
 	server   net.Server
 	storage  storage.Storage
+	refresh  *refresh.Worker
+	cancel   context.CancelFunc
 
 	now func() time.Time
 }
@@ -66,19 +95,43 @@ func New(cfg Config) *Service {
 		cfg.Now = time.Now
 	}
 
-	correios := correios.New()
+	if cfg.Correios == nil {
+		cfg.Correios = correios.New(correios.Options{})
+	}
+
 	healthChecker := gosundheit.New()
 
 	svc := &Service{
 		cfg:      cfg,
-		correios: correios,
+		correios: cfg.Correios,
 		health:   healthChecker,
 		logger:   log.WithField("component", "server"),
 		storage:  cfg.Storage,
 		now:      cfg.Now,
 	}
 
-	httpHandler := handler.New(correios, cfg.Storage, healthChecker, cfg.ReleaseMode, cfg.Now)
+	httpHandler := handler.New(handler.Config{
+		Correios:             cfg.Correios,
+		Storage:              cfg.Storage,
+		Health:               healthChecker,
+		APIToken:             cfg.APIToken,
+		NotFoundTTL:          cfg.NotFoundTTL,
+		MaxAge:               cfg.MaxAge,
+		StaleWhileRevalidate: cfg.StaleWhileRevalidate,
+		ReleaseMode:          cfg.ReleaseMode,
+		Now:                  cfg.Now,
+	})
+
+	if cfg.MaxAge > 0 && cfg.RefreshInterval > 0 {
+		svc.refresh = refresh.NewWorker(refresh.Config{
+			Correios:  cfg.Correios,
+			Storage:   cfg.Storage,
+			MaxAge:    cfg.MaxAge,
+			Interval:  cfg.RefreshInterval,
+			BatchSize: cfg.RefreshBatchSize,
+			Now:       cfg.Now,
+		})
+	}
 
 	svc.server = net.NewServer(cfg.HTTPServerConfig, httpHandler, svc.Shutdown)
 
@@ -97,6 +150,15 @@ func (s *Service) Run() error {
 		return errors.E(op, errors.KindUnexpected, err)
 	}
 
+	if detailer, ok := s.correios.(health.Detailer); ok {
+		if err := s.health.RegisterCheck(&checks.CustomCheck{
+			CheckName: "correios_circuit",
+			CheckFunc: health.NewDetailsCheckFunc(detailer),
+		}, gosundheit.ExecutionPeriod(10*time.Second)); err != nil {
+			return errors.E(op, errors.KindUnexpected, err)
+		}
+	}
+
 	if s.storage != nil {
 		if err := s.health.RegisterCheck(&checks.CustomCheck{
 			CheckName: "database",
@@ -109,6 +171,13 @@ func (s *Service) Run() error {
 		return errors.E(op, errors.KindUnexpected, "invalid storage configuration")
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	if s.refresh != nil {
+		go s.refresh.Run(ctx)
+	}
+
 	// Start Server
 	if err := s.server.Run(); err != nil {
 		return errors.E(op, "failed to start server", err)
@@ -119,5 +188,8 @@ func (s *Service) Run() error {
 
 func (s *Service) Shutdown() {
 	s.logger.Infof("%s: Stopping HTTP Server", app.Description)
+	if s.cancel != nil {
+		s.cancel()
+	}
 	s.storage.Close()
 }
@@ -0,0 +1,627 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memory implements an in-memory storage.Storage, suitable for tests
+// and small deployments that do not need PostgreSQL.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/pkg/log"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/sirupsen/logrus"
+)
+
+type Memory struct {
+	// writes serializes changes with transactions, which replace the maps
+	// when they commit.
+	writes sync.Mutex
+
+	mu            sync.RWMutex
+	addresses     map[string]*storage.Address
+	notFound      map[string]storage.NotFound
+	deltaVersions map[string]storage.DeltaVersion
+	searches      map[string]storage.SearchResult
+	logger        logrus.FieldLogger
+
+	now func() time.Time
+}
+
+var _ storage.Storage = (*Memory)(nil)
+
+// New returns an empty in-memory storage.
+func New(now func() time.Time) *Memory {
+	if now == nil {
+		now = time.Now
+	}
+
+	return &Memory{
+		addresses:     make(map[string]*storage.Address),
+		notFound:      make(map[string]storage.NotFound),
+		deltaVersions: make(map[string]storage.DeltaVersion),
+		searches:      make(map[string]storage.SearchResult),
+		logger:        log.WithField("component", "memory"),
+		now:           now,
+	}
+}
+
+func (m *Memory) Close() {
+	m.logger.Info("Closing memory storage")
+}
+
+func (m *Memory) Check(ctx context.Context) error {
+	return nil
+}
+
+func (m *Memory) CreateAddress(ctx context.Context, address *storage.Address) error {
+	const op errors.Op = "memory.CreateAddress"
+
+	if address == nil {
+		return errors.E(op, errors.KindBadRequest, "invalid address")
+	}
+
+	m.writes.Lock()
+	defer m.writes.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.addresses[address.CEP]; ok {
+		return errors.E(op, errors.KindAlreadyExists, fmt.Sprintf("cep %s already exists", address.CEP))
+	}
+
+	now := m.now()
+	address.CreatedAt = &now
+	address.UpdatedAt = &now
+	m.addresses[address.CEP] = clone(address)
+	delete(m.notFound, address.CEP)
+
+	return nil
+}
+
+func (m *Memory) UpdateAddress(ctx context.Context, cep string, updater storage.Updater) error {
+	const op errors.Op = "memory.UpdateAddress"
+
+	m.writes.Lock()
+	defer m.writes.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old, ok := m.addresses[cep]
+	if !ok {
+		return errors.E(op, errors.KindNotFound, fmt.Sprintf("cep %s not found", cep))
+	}
+
+	address, err := updater(clone(old))
+	if err != nil {
+		return err
+	}
+
+	if address == nil {
+		return errors.E(op, errors.KindBadRequest, "invalid address")
+	}
+
+	if address.CEP != cep {
+		if _, ok := m.addresses[address.CEP]; ok {
+			return errors.E(op, errors.KindAlreadyExists, fmt.Sprintf("cep %s already exists", address.CEP))
+		}
+	}
+
+	now := m.now()
+	address.CreatedAt = old.CreatedAt
+	address.UpdatedAt = &now
+
+	delete(m.addresses, cep)
+	m.addresses[address.CEP] = clone(address)
+
+	return nil
+}
+
+func (m *Memory) GetAddress(ctx context.Context, cep string) (*storage.Address, error) {
+	const op errors.Op = "memory.GetAddress"
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	address, ok := m.addresses[cep]
+	if !ok {
+		return nil, errors.E(op, errors.KindNotFound, fmt.Sprintf("cep %s not found", cep))
+	}
+
+	return clone(address), nil
+}
+
+func (m *Memory) BulkUpsertAddresses(ctx context.Context, addresses iter.Seq2[*storage.Address, error], progress func(stats storage.BulkStats)) (storage.BulkStats, error) {
+	const op errors.Op = "memory.BulkUpsertAddresses"
+
+	var stats storage.BulkStats
+	batch := make(map[string]*storage.Address, storage.BulkBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		m.writes.Lock()
+		m.mu.Lock()
+		now := m.now()
+		for cep, address := range batch {
+			address.CreatedAt = &now
+			if old, ok := m.addresses[cep]; ok {
+				address.CreatedAt = old.CreatedAt
+				stats.Updated++
+			} else {
+				stats.Created++
+			}
+
+			address.UpdatedAt = &now
+			m.addresses[cep] = clone(address)
+			delete(m.notFound, cep)
+		}
+		m.mu.Unlock()
+		m.writes.Unlock()
+
+		clear(batch)
+		if progress != nil {
+			progress(stats)
+		}
+	}
+
+	for address, err := range addresses {
+		if err != nil {
+			return stats, errors.E(op, err)
+		}
+
+		if address == nil {
+			return stats, errors.E(op, errors.KindBadRequest, "invalid address")
+		}
+
+		batch[address.CEP] = address
+		if len(batch) == storage.BulkBatchSize {
+			flush()
+		}
+	}
+	flush()
+
+	return stats, nil
+}
+
+func (m *Memory) DeleteAddress(ctx context.Context, cep string) error {
+	const op errors.Op = "memory.DeleteAddress"
+
+	m.writes.Lock()
+	defer m.writes.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.addresses[cep]; !ok {
+		return errors.E(op, errors.KindNotFound, fmt.Sprintf("cep %s not found", cep))
+	}
+
+	delete(m.addresses, cep)
+
+	return nil
+}
+
+func (m *Memory) ExportAddresses(ctx context.Context, params storage.ExportParams) iter.Seq2[*storage.Address, error] {
+	const op errors.Op = "memory.ExportAddresses"
+
+	return func(yield func(*storage.Address, error) bool) {
+		// Only the CEPs are collected, and each address is cloned as it is
+		// yielded, so the export does not hold a copy of the whole set.
+		m.mu.RLock()
+		ceps := make([]string, 0)
+		for cep, address := range m.addresses {
+			if params.State == "" || strings.EqualFold(address.State, params.State) {
+				ceps = append(ceps, cep)
+			}
+		}
+		m.mu.RUnlock()
+
+		sort.Strings(ceps)
+
+		for _, cep := range ceps {
+			if err := ctx.Err(); err != nil {
+				yield(nil, errors.E(op, errors.KindUnexpected, err))
+				return
+			}
+
+			m.mu.RLock()
+			address, ok := m.addresses[cep]
+			if ok {
+				address = clone(address)
+			}
+			m.mu.RUnlock()
+
+			// Addresses deleted since the export started are skipped.
+			if !ok {
+				continue
+			}
+
+			if !yield(address, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (m *Memory) ListAddresses(ctx context.Context, params storage.ListParams) ([]*storage.Address, error) {
+	const op errors.Op = "memory.ListAddresses"
+
+	if params.State == "" {
+		return nil, errors.E(op, errors.KindBadRequest, "param state is required")
+	}
+
+	if params.Pagination == nil {
+		return nil, errors.E(op, errors.KindUnexpected, "invalid pagination")
+	}
+
+	if params.Pagination.Offset < 0 {
+		return nil, errors.E(op, errors.KindBadRequest, "pagination offset must not be negative")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matches := make([]*storage.Address, 0)
+	for _, address := range m.addresses {
+		if strings.EqualFold(address.State, params.State) &&
+			(params.Type == storage.CEPTypeUnknown || address.Type == params.Type) {
+			matches = append(matches, address)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].CEP < matches[j].CEP
+	})
+
+	addresses := make([]*storage.Address, 0)
+	for i := params.Pagination.Offset; i < len(matches) && len(addresses) < params.Pagination.Limit; i++ {
+		addresses = append(addresses, clone(matches[i]))
+	}
+
+	return addresses, nil
+}
+
+func (m *Memory) SearchAddresses(ctx context.Context, params storage.SearchParams) ([]*storage.Address, error) {
+	const op errors.Op = "memory.SearchAddresses"
+
+	if err := params.Validate(); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	terms := params.Terms()
+	query := trigrams(storage.Fold(params.Query))
+
+	type match struct {
+		address *storage.Address
+		score   float64
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matches := make([]match, 0)
+	for _, address := range m.addresses {
+		if params.State != "" && !strings.EqualFold(address.State, params.State) {
+			continue
+		}
+
+		text := storage.SearchText(address)
+		if !containsAll(text, terms) {
+			continue
+		}
+
+		matches = append(matches, match{address: address, score: similarity(trigrams(text), query)})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].address.CEP < matches[j].address.CEP
+	})
+
+	addresses := make([]*storage.Address, 0)
+	for i := 0; i < len(matches) && len(addresses) < params.Limit; i++ {
+		addresses = append(addresses, clone(matches[i].address))
+	}
+
+	return addresses, nil
+}
+
+func (m *Memory) ListStaleAddresses(ctx context.Context, before time.Time, limit int) ([]*storage.Address, error) {
+	const op errors.Op = "memory.ListStaleAddresses"
+
+	if limit < 1 {
+		return nil, errors.E(op, errors.KindBadRequest, "limit must be positive")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matches := make([]*storage.Address, 0)
+	for _, address := range m.addresses {
+		if address.UpdatedAt.Before(before) {
+			matches = append(matches, address)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].UpdatedAt.Equal(*matches[j].UpdatedAt) {
+			return matches[i].UpdatedAt.Before(*matches[j].UpdatedAt)
+		}
+		return matches[i].CEP < matches[j].CEP
+	})
+
+	addresses := make([]*storage.Address, 0)
+	for i := 0; i < len(matches) && len(addresses) < limit; i++ {
+		addresses = append(addresses, clone(matches[i]))
+	}
+
+	return addresses, nil
+}
+
+func (m *Memory) CreateNotFound(ctx context.Context, notFound *storage.NotFound) error {
+	const op errors.Op = "memory.CreateNotFound"
+
+	if notFound == nil {
+		return errors.E(op, errors.KindBadRequest, "invalid not found record")
+	}
+
+	m.writes.Lock()
+	defer m.writes.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	notFound.CreatedAt = &now
+	m.notFound[notFound.CEP] = *notFound
+
+	return nil
+}
+
+func (m *Memory) GetNotFound(ctx context.Context, cep string) (*storage.NotFound, error) {
+	const op errors.Op = "memory.GetNotFound"
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	notFound, ok := m.notFound[cep]
+	if !ok || !notFound.ExpiresAt.After(m.now()) {
+		return nil, errors.E(op, errors.KindNotFound, fmt.Sprintf("no not found record for cep %s", cep))
+	}
+
+	createdAt := *notFound.CreatedAt
+	notFound.CreatedAt = &createdAt
+
+	return &notFound, nil
+}
+
+func containsAll(text string, terms []string) bool {
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// trigrams returns the trigrams of the words of s the way PostgreSQL's
+// pg_trgm does, so results rank as they do in the postgres storage.
+func trigrams(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+
+	return set
+}
+
+// similarity is the ratio of trigrams shared by a and b.
+func similarity(a, b map[string]struct{}) float64 {
+	var shared int
+	for trigram := range a {
+		if _, ok := b[trigram]; ok {
+			shared++
+		}
+	}
+
+	total := len(a) + len(b) - shared
+	if total == 0 {
+		return 0
+	}
+
+	return float64(shared) / float64(total)
+}
+
+func (m *Memory) CreateDeltaVersion(ctx context.Context, version *storage.DeltaVersion) error {
+	const op errors.Op = "memory.CreateDeltaVersion"
+
+	if version == nil || version.Version == "" {
+		return errors.E(op, errors.KindBadRequest, "invalid delta version")
+	}
+
+	m.writes.Lock()
+	defer m.writes.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.deltaVersions[version.Version]; ok {
+		return errors.E(op, errors.KindAlreadyExists, fmt.Sprintf("delta %s already applied", version.Version))
+	}
+
+	now := m.now()
+	version.AppliedAt = &now
+	m.deltaVersions[version.Version] = *version
+
+	return nil
+}
+
+func (m *Memory) GetDeltaVersion(ctx context.Context, version string) (*storage.DeltaVersion, error) {
+	const op errors.Op = "memory.GetDeltaVersion"
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	applied, ok := m.deltaVersions[version]
+	if !ok {
+		return nil, errors.E(op, errors.KindNotFound, fmt.Sprintf("delta %s not applied", version))
+	}
+
+	appliedAt := *applied.AppliedAt
+	applied.AppliedAt = &appliedAt
+
+	return &applied, nil
+}
+
+func (m *Memory) CreateSearchResult(ctx context.Context, result *storage.SearchResult) error {
+	const op errors.Op = "memory.CreateSearchResult"
+
+	if result == nil || result.Key == "" {
+		return errors.E(op, errors.KindBadRequest, "invalid search result")
+	}
+
+	m.writes.Lock()
+	defer m.writes.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	result.CreatedAt = &now
+
+	stored := *result
+	stored.CEPs = append([]string{}, result.CEPs...)
+	m.searches[result.Key] = stored
+
+	return nil
+}
+
+func (m *Memory) GetSearchResult(ctx context.Context, key string) (*storage.SearchResult, error) {
+	const op errors.Op = "memory.GetSearchResult"
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.searches[key]
+	if !ok {
+		return nil, errors.E(op, errors.KindNotFound, fmt.Sprintf("search %q not found", key))
+	}
+
+	result := stored
+	result.CEPs = append([]string{}, stored.CEPs...)
+
+	return &result, nil
+}
+
+// WithTx runs fn against a copy of the storage, which replaces it when fn
+// succeeds. Other changes wait for the transaction to finish.
+func (m *Memory) WithTx(ctx context.Context, fn func(tx storage.Storage) error) error {
+	m.writes.Lock()
+	defer m.writes.Unlock()
+
+	m.mu.RLock()
+	tx := &Memory{
+		addresses:     make(map[string]*storage.Address, len(m.addresses)),
+		notFound:      make(map[string]storage.NotFound, len(m.notFound)),
+		deltaVersions: make(map[string]storage.DeltaVersion, len(m.deltaVersions)),
+		searches:      make(map[string]storage.SearchResult, len(m.searches)),
+		logger:        m.logger,
+		now:           m.now,
+	}
+	// Stored addresses are never modified in place, so they can be shared.
+	for cep, address := range m.addresses {
+		tx.addresses[cep] = address
+	}
+	for cep, notFound := range m.notFound {
+		tx.notFound[cep] = notFound
+	}
+	for version, applied := range m.deltaVersions {
+		tx.deltaVersions[version] = applied
+	}
+	for key, result := range m.searches {
+		tx.searches[key] = result
+	}
+	m.mu.RUnlock()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.addresses, m.notFound, m.deltaVersions, m.searches = tx.addresses, tx.notFound, tx.deltaVersions, tx.searches
+	m.mu.Unlock()
+
+	return nil
+}
+
+// clone returns a deep copy so callers never share state with the store.
+func clone(address *storage.Address) *storage.Address {
+	if address == nil {
+		return nil
+	}
+
+	c := *address
+	if address.CreatedAt != nil {
+		createdAt := *address.CreatedAt
+		c.CreatedAt = &createdAt
+	}
+
+	if address.UpdatedAt != nil {
+		updatedAt := *address.UpdatedAt
+		c.UpdatedAt = &updatedAt
+	}
+
+	if address.NumberRange != nil {
+		numberRange := *address.NumberRange
+		c.NumberRange = &numberRange
+	}
+
+	if address.POBoxRanges != nil {
+		c.POBoxRanges = append([]storage.POBoxRange(nil), address.POBoxRanges...)
+	}
+
+	if address.CEPRanges != nil {
+		c.CEPRanges = append([]storage.CEPRange(nil), address.CEPRanges...)
+	}
+
+	if address.Children != nil {
+		c.Children = make([]*storage.Address, len(address.Children))
+		for i, child := range address.Children {
+			c.Children[i] = clone(child)
+		}
+	}
+
+	return &c
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/insighted4/correios-cep/pkg/app"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/insighted4/correios-cep/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	storagetest.Run(t, New(nil))
+}
+
+func TestMemory_Isolation(t *testing.T) {
+	m := New(app.StartDate)
+	ctx := context.Background()
+
+	a1 := storagetest.NewAddress()
+	require.NoError(t, m.CreateAddress(ctx, a1))
+	assert.Equal(t, app.StartDate(), *a1.CreatedAt)
+
+	// Mutating a returned address must not change the stored one.
+	a2, err := m.GetAddress(ctx, a1.CEP)
+	require.NoError(t, err)
+	a2.Children[0].City = "changed"
+
+	a3, err := m.GetAddress(ctx, a1.CEP)
+	require.NoError(t, err)
+	assert.Equal(t, a1.Children[0].City, a3.Children[0].City)
+}
+
+func TestMemory_Concurrent(t *testing.T) {
+	m := New(nil)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cep := fmt.Sprintf("%08d", i%10)
+			_ = m.CreateAddress(ctx, &storage.Address{CEP: cep, State: "GO"})
+			_, _ = m.GetAddress(ctx, cep)
+			_ = m.UpdateAddress(ctx, cep, func(old *storage.Address) (*storage.Address, error) {
+				old.City = "Goiânia"
+				return old, nil
+			})
+			_, _ = m.ListAddresses(ctx, storage.ListParams{State: "GO", Pagination: storage.NewPagination(0, 0)})
+		}(i)
+	}
+	wg.Wait()
+
+	addresses, err := m.ListAddresses(ctx, storage.ListParams{State: "GO", Pagination: storage.NewPagination(0, 0)})
+	require.NoError(t, err)
+	assert.Len(t, addresses, 10)
+}
@@ -15,7 +15,10 @@
 package storage
 
 import (
+	"fmt"
 	"time"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
 )
 
 type Address struct {
@@ -26,6 +29,157 @@ type Address struct {
 	Location     string     `json:"location" db:"location"`
 	Children     []*Address `json:"children" db:"children"`
 
+	// Unit is the name of the big user or postal unit owning the CEP
+	// (e.g. "Edifício Triângulo").
+	Unit string `json:"unit" db:"unit"`
+	// Type tells what the CEP is assigned to: a whole city, a street, a big
+	// user, a postal unit or a community PO box.
+	Type CEPType `json:"type" db:"type"`
+	// Complement is additional text about the street (e.g. a range of numbers).
+	Complement string `json:"complement" db:"complement"`
+	// Subordinate is the district or village within the city.
+	Subordinate string `json:"subordinate" db:"subordinate"`
+	// Status is the situation of the CEP as reported upstream.
+	Status string `json:"status" db:"status"`
+	// POBoxRanges lists the PO boxes served by a postal unit.
+	POBoxRanges []POBoxRange `json:"po_box_ranges" db:"po_box_ranges"`
+	// CEPRanges lists the CEPs assigned to a city.
+	CEPRanges []CEPRange `json:"cep_ranges" db:"cep_ranges"`
+
+	// Street is the street name parsed out of Location, without the range of
+	// numbers the CEP covers.
+	Street string `json:"street" db:"street"`
+	// NumberRange is the part of the street covered by the CEP, when the
+	// street is split among several CEPs.
+	NumberRange *NumberRange `json:"number_range" db:"number_range"`
+
 	CreatedAt *time.Time `json:"created_at,omitempty,omitempty"  db:"cep"`
 	UpdatedAt *time.Time `json:"updated_at,omitempty,omitempty"  db:"cep"`
 }
+
+// NotFound records a CEP unknown upstream, so it is answered as not found
+// without looking it up again until ExpiresAt.
+type NotFound struct {
+	CEP       string     `json:"cep" db:"cep"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	CreatedAt *time.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+// DeltaVersion records an eDNE delta applied to the addresses, so applying
+// it again is a no-op.
+type DeltaVersion struct {
+	Version   string     `json:"version" db:"version"`
+	AppliedAt *time.Time `json:"applied_at,omitempty" db:"applied_at"`
+}
+
+// SearchResult records the CEPs an upstream address search answered, so
+// repeating the search is answered from the stored addresses.
+type SearchResult struct {
+	Key       string     `json:"key" db:"key"`
+	CEPs      []string   `json:"ceps" db:"ceps"`
+	CreatedAt *time.Time `json:"created_at,omitempty" db:"created_at"`
+}
+
+// POBoxRange is an interval of PO box numbers.
+type POBoxRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// CEPRange is an interval of CEPs.
+type CEPRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// CEPType classifies what a CEP is assigned to.
+type CEPType string
+
+const (
+	CEPTypeUnknown CEPType = ""
+	// CEPTypeLocality is a single CEP shared by a whole city or village.
+	CEPTypeLocality CEPType = "localidade"
+	// CEPTypeStreet is a CEP assigned to a street or part of it.
+	CEPTypeStreet CEPType = "logradouro"
+	// CEPTypeBigUser is a CEP exclusive to an organization receiving a
+	// large volume of mail (e.g. a building, a university campus).
+	CEPTypeBigUser CEPType = "grande_usuario"
+	// CEPTypeUnit is a CEP of a Correios postal unit, usually holding PO boxes.
+	CEPTypeUnit CEPType = "unidade_operacional"
+	// CEPTypeCommunityBox is a community PO box (caixa postal comunitária).
+	CEPTypeCommunityBox CEPType = "caixa_postal_comunitaria"
+)
+
+var cepTypes = []CEPType{
+	CEPTypeLocality,
+	CEPTypeStreet,
+	CEPTypeBigUser,
+	CEPTypeUnit,
+	CEPTypeCommunityBox,
+}
+
+// CEPTypes returns every known CEP type.
+func CEPTypes() []CEPType {
+	return append([]CEPType(nil), cepTypes...)
+}
+
+// ParseCEPType validates s as a CEP type. An empty string is the unknown type.
+func ParseCEPType(s string) (CEPType, error) {
+	const op errors.Op = "storage.ParseCEPType"
+
+	t := CEPType(s)
+	if t == CEPTypeUnknown || t.Valid() {
+		return t, nil
+	}
+
+	return CEPTypeUnknown, errors.E(op, errors.KindBadRequest, fmt.Sprintf("invalid cep type %q", s))
+}
+
+// Valid reports whether t is one of the known CEP types.
+func (t CEPType) Valid() bool {
+	for _, known := range cepTypes {
+		if t == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t CEPType) String() string {
+	return string(t)
+}
+
+// Side of the street, by the parity of the house numbers.
+type Side string
+
+const (
+	SideBoth Side = "both"
+	SideOdd  Side = "odd"
+	SideEven Side = "even"
+)
+
+// NumberRange is an interval of house numbers on one or both sides of a
+// street. A zero From starts at the beginning of the street and a zero To
+// goes up to its end.
+type NumberRange struct {
+	Side Side `json:"side"`
+	From int  `json:"from,omitempty"`
+	To   int  `json:"to,omitempty"`
+}
+
+// Contains reports whether the house number n is within the range.
+func (r *NumberRange) Contains(n int) bool {
+	if n < 1 || (r.From > 0 && n < r.From) || (r.To > 0 && n > r.To) {
+		return false
+	}
+
+	switch r.Side {
+	case SideOdd:
+		return n%2 == 1
+	case SideEven:
+		return n%2 == 0
+	default:
+		return true
+	}
+}
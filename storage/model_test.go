@@ -0,0 +1,57 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCEPType(t *testing.T) {
+	for _, expected := range append(CEPTypes(), CEPTypeUnknown) {
+		actual, err := ParseCEPType(expected.String())
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	_, err := ParseCEPType("5")
+	assert.True(t, errors.Is(err, errors.KindBadRequest))
+}
+
+func TestNumberRangeContains(t *testing.T) {
+	tests := []struct {
+		r        NumberRange
+		number   int
+		expected bool
+	}{
+		{NumberRange{Side: SideBoth, To: 1000}, 1, true},
+		{NumberRange{Side: SideBoth, To: 1000}, 1000, true},
+		{NumberRange{Side: SideBoth, To: 1000}, 1001, false},
+		{NumberRange{Side: SideOdd, From: 1001}, 1001, true},
+		{NumberRange{Side: SideOdd, From: 1001}, 99999, true},
+		{NumberRange{Side: SideOdd, From: 1001}, 1002, false},
+		{NumberRange{Side: SideOdd, From: 1001}, 999, false},
+		{NumberRange{Side: SideEven, From: 2, To: 10}, 10, true},
+		{NumberRange{Side: SideEven, From: 2, To: 10}, 7, false},
+		{NumberRange{Side: SideBoth}, 0, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, tt.r.Contains(tt.number), "%+v contains %d", tt.r, tt.number)
+	}
+}
@@ -16,6 +16,9 @@ package postgres
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/insighted4/correios-cep/pkg/errors"
 	"github.com/insighted4/correios-cep/storage"
@@ -24,16 +27,28 @@ import (
 
 func (p *Postgres) CreateAddress(ctx context.Context, address *storage.Address) error {
 	const op errors.Op = "postgres.CreateAddress"
-	query := `INSERT INTO addresses (
+	query := `WITH found AS (
+				DELETE FROM not_found WHERE cep = $1
+			)
+			INSERT INTO addresses (
 				cep,
 				state,
 				city,
 				neighborhood,
-            	location,
-                children,
+				location,
+				children,
+				unit,
+				type,
+				complement,
+				subordinate,
+				status,
+				po_box_ranges,
+				cep_ranges,
+				street,
+				number_range,
 				created_at,
 				updated_at
-    		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8); 
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17);
 	`
 
 	now := p.now()
@@ -47,6 +62,15 @@ func (p *Postgres) CreateAddress(ctx context.Context, address *storage.Address)
 		address.Neighborhood,
 		address.Location,
 		address.Children,
+		address.Unit,
+		address.Type,
+		address.Complement,
+		address.Subordinate,
+		address.Status,
+		address.POBoxRanges,
+		address.CEPRanges,
+		address.Street,
+		address.NumberRange,
 		address.CreatedAt,
 		address.UpdatedAt,
 	); err != nil {
@@ -81,9 +105,18 @@ func (p *Postgres) UpdateAddress(ctx context.Context, cep string, updater storag
 				neighborhood = $4,
 				location = $5,
 				children = $6,
-				updated_at = $7
+				unit = $7,
+				type = $8,
+				complement = $9,
+				subordinate = $10,
+				status = $11,
+				po_box_ranges = $12,
+				cep_ranges = $13,
+				street = $14,
+				number_range = $15,
+				updated_at = $16
 			WHERE
-				cep = $8;
+				cep = $17;
 		`
 
 		_, err = tx.Exec(ctx, query,
@@ -93,6 +126,15 @@ func (p *Postgres) UpdateAddress(ctx context.Context, cep string, updater storag
 			address.Neighborhood,
 			address.Location,
 			address.Children,
+			address.Unit,
+			address.Type,
+			address.Complement,
+			address.Subordinate,
+			address.Status,
+			address.POBoxRanges,
+			address.CEPRanges,
+			address.Street,
+			address.NumberRange,
 			address.UpdatedAt,
 			cep,
 		)
@@ -106,6 +148,21 @@ func (p *Postgres) UpdateAddress(ctx context.Context, cep string, updater storag
 	return p.ExecTx(ctx, updateFn, op)
 }
 
+func (p *Postgres) DeleteAddress(ctx context.Context, cep string) error {
+	const op errors.Op = "postgres.DeleteAddress"
+
+	tag, err := p.db.Exec(ctx, `DELETE FROM addresses WHERE cep = $1;`, cep)
+	if err != nil {
+		return errors.E(op, kind(err), err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return errors.E(op, errors.KindNotFound, fmt.Sprintf("cep %s not found", cep))
+	}
+
+	return nil
+}
+
 func (p *Postgres) GetAddress(ctx context.Context, cep string) (*storage.Address, error) {
 	const op errors.Op = "postgres.GetAddress"
 	return p.get(ctx, cep, op)
@@ -120,6 +177,15 @@ func (p *Postgres) get(ctx context.Context, cep string, op errors.Op) (*storage.
 			neighborhood,
 			location,
 			children,
+			unit,
+			type,
+			complement,
+			subordinate,
+			status,
+			po_box_ranges,
+			cep_ranges,
+			street,
+			number_range,
 			created_at,
 			updated_at
 		FROM addresses
@@ -141,6 +207,10 @@ func (p *Postgres) ListAddresses(ctx context.Context, params storage.ListParams)
 		return nil, errors.E(op, errors.KindUnexpected, "invalid pagination")
 	}
 
+	if params.Pagination.Offset < 0 {
+		return nil, errors.E(op, errors.KindBadRequest, "pagination offset must not be negative")
+	}
+
 	query := `
 		SELECT 
 			cep,
@@ -149,11 +219,24 @@ func (p *Postgres) ListAddresses(ctx context.Context, params storage.ListParams)
 			neighborhood,
 			location,
 			children,
+			unit,
+			type,
+			complement,
+			subordinate,
+			status,
+			po_box_ranges,
+			cep_ranges,
+			street,
+			number_range,
 			created_at,
 			updated_at
-		FROM addresses WHERE lower(state) = lower($1) ORDER BY cep ASC LIMIT $2 OFFSET $3;
+		FROM addresses
+		WHERE
+			lower(state) = lower($1) AND
+			($2 = '' OR type = $2)
+		ORDER BY cep ASC LIMIT $3 OFFSET $4;
 	`
-	rows, err := p.db.Query(ctx, query, params.State, params.Pagination.Limit, params.Pagination.Offset)
+	rows, err := p.db.Query(ctx, query, params.State, params.Type, params.Pagination.Limit, params.Pagination.Offset)
 	if err != nil {
 		return nil, err
 	}
@@ -176,6 +259,127 @@ func (p *Postgres) ListAddresses(ctx context.Context, params storage.ListParams)
 	return addresses, nil
 }
 
+// searchText must match the expression of addresses_search_idx, so that
+// searches use the trigram index.
+const searchText = `address_search_text(city, neighborhood, location)`
+
+func (p *Postgres) SearchAddresses(ctx context.Context, params storage.SearchParams) ([]*storage.Address, error) {
+	const op errors.Op = "postgres.SearchAddresses"
+
+	if err := params.Validate(); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	terms := params.Terms()
+	patterns := make([]string, len(terms))
+	for i, term := range terms {
+		patterns[i] = "%" + likeEscaper.Replace(term) + "%"
+	}
+
+	query := `
+		SELECT 
+			cep,
+			state,
+			city,
+			neighborhood,
+			location,
+			children,
+			unit,
+			type,
+			complement,
+			subordinate,
+			status,
+			po_box_ranges,
+			cep_ranges,
+			street,
+			number_range,
+			created_at,
+			updated_at
+		FROM addresses
+		WHERE
+			($1 = '' OR lower(state) = lower($1)) AND
+			` + searchText + ` LIKE ALL ($2)
+		ORDER BY similarity(` + searchText + `, $3) DESC, cep ASC LIMIT $4;
+	`
+	rows, err := p.db.Query(ctx, query, params.State, patterns, storage.Fold(params.Query), params.Limit)
+	if err != nil {
+		return nil, errors.E(op, kind(err), err)
+	}
+	defer rows.Close()
+
+	addresses := make([]*storage.Address, 0)
+	for rows.Next() {
+		p, err := scan(rows, op)
+		if err != nil {
+			return nil, err
+		}
+
+		addresses = append(addresses, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.E(op, kind(err), err)
+	}
+
+	return addresses, nil
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (p *Postgres) ListStaleAddresses(ctx context.Context, before time.Time, limit int) ([]*storage.Address, error) {
+	const op errors.Op = "postgres.ListStaleAddresses"
+
+	if limit < 1 {
+		return nil, errors.E(op, errors.KindBadRequest, "limit must be positive")
+	}
+
+	query := `
+		SELECT 
+			cep,
+			state,
+			city,
+			neighborhood,
+			location,
+			children,
+			unit,
+			type,
+			complement,
+			subordinate,
+			status,
+			po_box_ranges,
+			cep_ranges,
+			street,
+			number_range,
+			created_at,
+			updated_at
+		FROM addresses
+		WHERE updated_at < $1
+		ORDER BY updated_at ASC, cep ASC LIMIT $2;
+	`
+	rows, err := p.db.Query(ctx, query, before, limit)
+	if err != nil {
+		return nil, errors.E(op, kind(err), err)
+	}
+	defer rows.Close()
+
+	addresses := make([]*storage.Address, 0)
+	for rows.Next() {
+		p, err := scan(rows, op)
+		if err != nil {
+			return nil, err
+		}
+
+		addresses = append(addresses, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.E(op, kind(err), err)
+	}
+
+	return addresses, nil
+}
+
 func scan(row pgx.Row, op errors.Op) (*storage.Address, error) {
 	var address storage.Address
 	if err := row.Scan(
@@ -185,6 +389,15 @@ func scan(row pgx.Row, op errors.Op) (*storage.Address, error) {
 		&address.Neighborhood,
 		&address.Location,
 		&address.Children,
+		&address.Unit,
+		&address.Type,
+		&address.Complement,
+		&address.Subordinate,
+		&address.Status,
+		&address.POBoxRanges,
+		&address.CEPRanges,
+		&address.Street,
+		&address.NumberRange,
 		&address.CreatedAt,
 		&address.UpdatedAt,
 	); err != nil {
@@ -18,9 +18,10 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/brianvoe/gofakeit/v6"
-	"github.com/insighted4/correios-cep/pkg/app"
+	"github.com/insighted4/correios-cep/migrations"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -48,6 +49,15 @@ func setup(t *testing.T) {
 			t.Fatal(err)
 		}
 
+		all, err := migrations.All()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := pg.MigrateUp(context.Background(), all); err != nil {
+			t.Fatal(err)
+		}
+
 		postgres = pg
 	}
 }
@@ -63,7 +73,8 @@ func newTestPostgres(t *testing.T) (*Postgres, error) {
 		return nil, err
 	}
 
-	pg, err := Connect(context.Background(), cfg, app.StartDate)
+	// The conformance suite builds expiries from the wall clock.
+	pg, err := Connect(context.Background(), cfg, time.Now().UTC)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,226 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"iter"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// bulkColumns are the columns copied into the staging table. ord keeps the
+// order of the addresses, so the last one of a CEP wins.
+var bulkColumns = []string{
+	"cep",
+	"state",
+	"city",
+	"neighborhood",
+	"location",
+	"children",
+	"unit",
+	"type",
+	"complement",
+	"subordinate",
+	"status",
+	"po_box_ranges",
+	"cep_ranges",
+	"street",
+	"number_range",
+	"created_at",
+	"updated_at",
+	"ord",
+}
+
+func (p *Postgres) BulkUpsertAddresses(ctx context.Context, addresses iter.Seq2[*storage.Address, error], progress func(stats storage.BulkStats)) (storage.BulkStats, error) {
+	const op errors.Op = "postgres.BulkUpsertAddresses"
+
+	var stats storage.BulkStats
+	rows := make([][]any, 0, storage.BulkBatchSize)
+	flush := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+
+		created, updated, err := p.upsertBatch(ctx, rows)
+		if err != nil {
+			return errors.E(op, kind(err), err)
+		}
+
+		stats.Created += created
+		stats.Updated += updated
+		rows = rows[:0]
+
+		if progress != nil {
+			progress(stats)
+		}
+
+		return nil
+	}
+
+	for address, err := range addresses {
+		if err != nil {
+			return stats, errors.E(op, err)
+		}
+
+		if address == nil {
+			return stats, errors.E(op, errors.KindBadRequest, "invalid address")
+		}
+
+		now := p.now()
+		address.CreatedAt = &now
+		address.UpdatedAt = &now
+
+		rows = append(rows, []any{
+			address.CEP,
+			address.State,
+			address.City,
+			address.Neighborhood,
+			address.Location,
+			address.Children,
+			address.Unit,
+			address.Type,
+			address.Complement,
+			address.Subordinate,
+			address.Status,
+			address.POBoxRanges,
+			address.CEPRanges,
+			address.Street,
+			address.NumberRange,
+			address.CreatedAt,
+			address.UpdatedAt,
+			len(rows),
+		})
+
+		if len(rows) == storage.BulkBatchSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// upsertBatch copies rows into a staging table and merges them into
+// addresses in a single statement, returning how many were created and
+// updated.
+func (p *Postgres) upsertBatch(ctx context.Context, rows [][]any) (int, int, error) {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Within WithTx, the staging table outlives the batch, until the
+	// transaction commits.
+	if _, err := tx.Exec(ctx, `CREATE TEMP TABLE IF NOT EXISTS address_staging (
+				LIKE addresses INCLUDING DEFAULTS,
+				ord INTEGER NOT NULL
+			) ON COMMIT DROP;
+	`); err != nil {
+		return 0, 0, err
+	}
+
+	if _, err := tx.Exec(ctx, `TRUNCATE address_staging;`); err != nil {
+		return 0, 0, err
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"address_staging"}, bulkColumns, pgx.CopyFromRows(rows)); err != nil {
+		return 0, 0, err
+	}
+
+	query := `WITH upserted AS (
+				INSERT INTO addresses (
+					cep,
+					state,
+					city,
+					neighborhood,
+					location,
+					children,
+					unit,
+					type,
+					complement,
+					subordinate,
+					status,
+					po_box_ranges,
+					cep_ranges,
+					street,
+					number_range,
+					created_at,
+					updated_at
+				)
+				SELECT DISTINCT ON (cep)
+					cep,
+					state,
+					city,
+					neighborhood,
+					location,
+					children,
+					unit,
+					type,
+					complement,
+					subordinate,
+					status,
+					po_box_ranges,
+					cep_ranges,
+					street,
+					number_range,
+					created_at,
+					updated_at
+				FROM address_staging
+				ORDER BY cep, ord DESC
+				ON CONFLICT (cep) DO UPDATE SET
+					state = EXCLUDED.state,
+					city = EXCLUDED.city,
+					neighborhood = EXCLUDED.neighborhood,
+					location = EXCLUDED.location,
+					children = EXCLUDED.children,
+					unit = EXCLUDED.unit,
+					type = EXCLUDED.type,
+					complement = EXCLUDED.complement,
+					subordinate = EXCLUDED.subordinate,
+					status = EXCLUDED.status,
+					po_box_ranges = EXCLUDED.po_box_ranges,
+					cep_ranges = EXCLUDED.cep_ranges,
+					street = EXCLUDED.street,
+					number_range = EXCLUDED.number_range,
+					updated_at = EXCLUDED.updated_at
+				RETURNING xmax = 0 AS inserted
+			)
+			SELECT count(*) FILTER (WHERE inserted), count(*) FILTER (WHERE NOT inserted) FROM upserted;
+	`
+
+	var created, updated int
+	if err := tx.QueryRow(ctx, query).Scan(&created, &updated); err != nil {
+		return 0, 0, err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM not_found USING address_staging WHERE not_found.cep = address_staging.cep;`); err != nil {
+		return 0, 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, err
+	}
+
+	return created, updated, nil
+}
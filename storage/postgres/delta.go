@@ -0,0 +1,69 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+)
+
+func (p *Postgres) CreateDeltaVersion(ctx context.Context, version *storage.DeltaVersion) error {
+	const op errors.Op = "postgres.CreateDeltaVersion"
+	query := `INSERT INTO dne_deltas (
+				version,
+				applied_at
+			) VALUES ($1, $2);
+	`
+
+	if version == nil || version.Version == "" {
+		return errors.E(op, errors.KindBadRequest, "invalid delta version")
+	}
+
+	now := p.now()
+	version.AppliedAt = &now
+
+	if _, err := p.db.Exec(ctx, query,
+		version.Version,
+		version.AppliedAt,
+	); err != nil {
+		return errors.E(op, kind(err), err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetDeltaVersion(ctx context.Context, version string) (*storage.DeltaVersion, error) {
+	const op errors.Op = "postgres.GetDeltaVersion"
+	query := `
+		SELECT
+			version,
+			applied_at
+		FROM dne_deltas
+		WHERE
+			version = $1;
+	`
+
+	var applied storage.DeltaVersion
+	if err := p.db.QueryRow(ctx, query, version).Scan(
+		&applied.Version,
+		&applied.AppliedAt,
+	); err != nil {
+		return nil, errors.E(op, kind(err), err)
+	}
+
+	return &applied, nil
+}
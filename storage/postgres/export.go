@@ -0,0 +1,104 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// exportBatchSize is the number of addresses fetched from the export cursor
+// at once.
+const exportBatchSize = 1000
+
+func (p *Postgres) ExportAddresses(ctx context.Context, params storage.ExportParams) iter.Seq2[*storage.Address, error] {
+	const op errors.Op = "postgres.ExportAddresses"
+
+	return func(yield func(*storage.Address, error) bool) {
+		if err := p.export(ctx, params, yield); err != nil {
+			yield(nil, errors.E(op, kind(err), err))
+		}
+	}
+}
+
+// export reads the addresses through a server-side cursor, one batch at a
+// time, and yields them once the batch is read, since the transaction can
+// only run one query at a time.
+func (p *Postgres) export(ctx context.Context, params storage.ExportParams, yield func(*storage.Address, error) bool) error {
+	const op errors.Op = "postgres.export"
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `DECLARE addresses_export NO SCROLL CURSOR FOR
+		SELECT
+			cep,
+			state,
+			city,
+			neighborhood,
+			location,
+			children,
+			unit,
+			type,
+			complement,
+			subordinate,
+			status,
+			po_box_ranges,
+			cep_ranges,
+			street,
+			number_range,
+			created_at,
+			updated_at
+		FROM addresses
+		WHERE $1 = '' OR lower(state) = lower($1)
+		ORDER BY cep ASC;
+	`
+	if _, err := tx.Exec(ctx, query, params.State); err != nil {
+		return err
+	}
+
+	batch := make([]*storage.Address, 0, exportBatchSize)
+	for {
+		rows, err := tx.Query(ctx, fmt.Sprintf(`FETCH FORWARD %d FROM addresses_export;`, exportBatchSize))
+		if err != nil {
+			return err
+		}
+
+		batch, err = pgx.AppendRows(batch[:0], rows, func(row pgx.CollectableRow) (*storage.Address, error) {
+			return scan(row, op)
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, address := range batch {
+			if !yield(address, nil) {
+				return nil
+			}
+		}
+
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/insighted4/correios-cep/migrations"
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/jackc/pgx/v5"
+)
+
+// migrationLock is the advisory lock held while migrating, so concurrent
+// runs, such as several servers starting with auto-migrate, apply each
+// migration once.
+const migrationLock = 0x636570 // "cep"
+
+// MigrationStatus tells whether a migration was applied.
+type MigrationStatus struct {
+	migrations.Migration
+	AppliedAt *time.Time
+}
+
+// MigrateUp applies the pending migrations in order, each in its own
+// transaction, returning those applied.
+func (p *Postgres) MigrateUp(ctx context.Context, all []migrations.Migration) ([]migrations.Migration, error) {
+	const op errors.Op = "postgres.MigrateUp"
+
+	var applied []migrations.Migration
+	for {
+		m, err := p.migrate(ctx, func(tx pgx.Tx, done map[int64]time.Time) (*migrations.Migration, error) {
+			for _, m := range all {
+				if _, ok := done[m.Version]; ok {
+					continue
+				}
+
+				if _, err := tx.Exec(ctx, m.Up); err != nil {
+					return nil, err
+				}
+
+				if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2);`, m.Version, m.Name); err != nil {
+					return nil, err
+				}
+
+				return &m, nil
+			}
+
+			return nil, nil
+		})
+		if err != nil {
+			return applied, errors.E(op, kind(err), err)
+		}
+
+		if m == nil {
+			return applied, nil
+		}
+
+		p.logger.Infof("Applied migration %s", m)
+		applied = append(applied, *m)
+	}
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first,
+// returning those reverted.
+func (p *Postgres) MigrateDown(ctx context.Context, all []migrations.Migration, steps int) ([]migrations.Migration, error) {
+	const op errors.Op = "postgres.MigrateDown"
+
+	var reverted []migrations.Migration
+	for len(reverted) < steps {
+		m, err := p.migrate(ctx, func(tx pgx.Tx, done map[int64]time.Time) (*migrations.Migration, error) {
+			var last int64
+			for version := range done {
+				last = max(last, version)
+			}
+
+			if last == 0 {
+				return nil, nil
+			}
+
+			for _, m := range all {
+				if m.Version != last {
+					continue
+				}
+
+				if _, err := tx.Exec(ctx, m.Down); err != nil {
+					return nil, err
+				}
+
+				if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1;`, m.Version); err != nil {
+					return nil, err
+				}
+
+				return &m, nil
+			}
+
+			return nil, errors.E(op, errors.KindUnexpected, fmt.Sprintf("migration %d is applied but unknown to this version", last))
+		})
+		if err != nil {
+			return reverted, errors.E(op, kind(err), err)
+		}
+
+		if m == nil {
+			break
+		}
+
+		p.logger.Infof("Reverted migration %s", m)
+		reverted = append(reverted, *m)
+	}
+
+	return reverted, nil
+}
+
+// MigrationStatus returns every migration of all, with the time applied
+// migrations were applied at.
+func (p *Postgres) MigrationStatus(ctx context.Context, all []migrations.Migration) ([]MigrationStatus, error) {
+	const op errors.Op = "postgres.MigrationStatus"
+
+	var statuses []MigrationStatus
+	_, err := p.migrate(ctx, func(tx pgx.Tx, done map[int64]time.Time) (*migrations.Migration, error) {
+		for _, m := range all {
+			status := MigrationStatus{Migration: m}
+			if at, ok := done[m.Version]; ok {
+				status.AppliedAt = &at
+			}
+			statuses = append(statuses, status)
+		}
+
+		return nil, nil
+	})
+	if err != nil {
+		return nil, errors.E(op, kind(err), err)
+	}
+
+	return statuses, nil
+}
+
+// migrate runs fn in a transaction holding the migration lock, with the
+// versions applied so far. fn returns the migration it ran, which is
+// committed, or nil when there is nothing to do.
+func (p *Postgres) migrate(ctx context.Context, fn func(tx pgx.Tx, done map[int64]time.Time) (*migrations.Migration, error)) (*migrations.Migration, error) {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1);`, migrationLock); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations
+			(
+				version    BIGINT                    NOT NULL PRIMARY KEY,
+				name       TEXT                      NOT NULL,
+				applied_at TIMESTAMPTZ DEFAULT now() NOT NULL
+			);
+	`); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `SELECT version, applied_at FROM schema_migrations;`)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[int64]time.Time)
+	var (
+		version   int64
+		appliedAt time.Time
+	)
+	if _, err := pgx.ForEachRow(rows, []any{&version, &appliedAt}, func() error {
+		done[version] = appliedAt
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	m, err := fn(tx, done)
+	if err != nil || m == nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
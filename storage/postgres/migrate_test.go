@@ -0,0 +1,65 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/insighted4/correios-cep/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	if shouldSkip() {
+		t.SkipNow()
+	}
+
+	setup(t)
+	ctx := context.Background()
+
+	all, err := migrations.All()
+	require.NoError(t, err)
+
+	statuses, err := postgres.MigrationStatus(ctx, all)
+	require.NoError(t, err)
+	require.Len(t, statuses, len(all))
+	for _, status := range statuses {
+		assert.NotNil(t, status.AppliedAt, status.String())
+	}
+
+	extra := migrations.Migration{
+		Version: all[len(all)-1].Version + 1000,
+		Name:    "create_migrate_test",
+		Up:      `CREATE TABLE migrate_test (id INT);`,
+		Down:    `DROP TABLE migrate_test;`,
+	}
+
+	applied, err := postgres.MigrateUp(ctx, append(all, extra))
+	require.NoError(t, err)
+	assert.Equal(t, []migrations.Migration{extra}, applied)
+
+	applied, err = postgres.MigrateUp(ctx, append(all, extra))
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	reverted, err := postgres.MigrateDown(ctx, append(all, extra), 1)
+	require.NoError(t, err)
+	assert.Equal(t, []migrations.Migration{extra}, reverted)
+
+	_, err = postgres.db.Exec(ctx, `SELECT 1 FROM migrate_test;`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,73 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+)
+
+func (p *Postgres) CreateNotFound(ctx context.Context, notFound *storage.NotFound) error {
+	const op errors.Op = "postgres.CreateNotFound"
+	query := `INSERT INTO not_found (
+				cep,
+				expires_at,
+				created_at
+			) VALUES ($1, $2, $3)
+			ON CONFLICT (cep) DO UPDATE SET
+				expires_at = EXCLUDED.expires_at,
+				created_at = EXCLUDED.created_at;
+	`
+
+	now := p.now()
+	notFound.CreatedAt = &now
+
+	if _, err := p.db.Exec(ctx, query,
+		notFound.CEP,
+		notFound.ExpiresAt,
+		notFound.CreatedAt,
+	); err != nil {
+		return errors.E(op, kind(err), err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetNotFound(ctx context.Context, cep string) (*storage.NotFound, error) {
+	const op errors.Op = "postgres.GetNotFound"
+	query := `
+		SELECT
+			cep,
+			expires_at,
+			created_at
+		FROM not_found
+		WHERE
+			cep = $1 AND
+			expires_at > $2;
+	`
+
+	var notFound storage.NotFound
+	if err := p.db.QueryRow(ctx, query, cep, p.now()).Scan(
+		&notFound.CEP,
+		&notFound.ExpiresAt,
+		&notFound.CreatedAt,
+	); err != nil {
+		return nil, errors.E(op, kind(err), err)
+	}
+
+	return &notFound, nil
+}
@@ -22,12 +22,23 @@ import (
 	"github.com/insighted4/correios-cep/pkg/log"
 	"github.com/insighted4/correios-cep/storage"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sirupsen/logrus"
 )
 
+// querier runs queries on the pool, or on a transaction in WithTx.
+type querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 type Postgres struct {
-	db     *pgxpool.Pool
+	pool   *pgxpool.Pool
+	db     querier
+	inTx   bool
 	logger logrus.FieldLogger
 
 	now func() time.Time
@@ -54,18 +65,22 @@ func Connect(ctx context.Context, cfg *pgxpool.Config, now func() time.Time) (*P
 		return nil, errors.E(op, errors.KindUnexpected, err)
 	}
 
-	return &Postgres{db: db, logger: logger, now: now}, nil
+	return &Postgres{pool: db, db: db, logger: logger, now: now}, nil
 }
 
 func (p *Postgres) Close() {
+	if p.inTx {
+		return
+	}
+
 	p.logger.Info("Closing database")
-	p.db.Close()
+	p.pool.Close()
 }
 
 func (p *Postgres) Check(ctx context.Context) error {
 	const op errors.Op = "postgres.Check"
 
-	if err := p.db.Ping(ctx); err != nil {
+	if err := p.pool.Ping(ctx); err != nil {
 		return errors.E(op, errors.KindUnexpected, err)
 	}
 
@@ -79,8 +94,11 @@ func (p *Postgres) ExecTx(ctx context.Context, fn func(tx pgx.Tx) error, op erro
 	}
 	defer tx.Rollback(ctx)
 
-	if _, err := tx.Exec(ctx, `SET TRANSACTION ISOLATION LEVEL SERIALIZABLE;`); err != nil {
-		return errors.E(op, kind(err), err)
+	// Within WithTx, tx is a savepoint of a transaction already under way.
+	if !p.inTx {
+		if _, err := tx.Exec(ctx, `SET TRANSACTION ISOLATION LEVEL SERIALIZABLE;`); err != nil {
+			return errors.E(op, kind(err), err)
+		}
 	}
 	if err := fn(tx); err != nil {
 		return err
@@ -88,23 +106,38 @@ func (p *Postgres) ExecTx(ctx context.Context, fn func(tx pgx.Tx) error, op erro
 	return tx.Commit(ctx)
 }
 
+// WithTx runs fn in a transaction, at the default isolation level so that
+// long transactions do not fail against concurrent lookups.
+func (p *Postgres) WithTx(ctx context.Context, fn func(tx storage.Storage) error) error {
+	const op errors.Op = "postgres.WithTx"
+
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return errors.E(op, kind(err), err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(&Postgres{pool: p.pool, db: tx, inTx: true, logger: p.logger, now: p.now}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errors.E(op, kind(err), err)
+	}
+
+	return nil
+}
+
+const pgErrUniqueViolation = "23505"
+
 func kind(err error) int {
+	var pgErr *pgconn.PgError
 	switch {
-	case err.Error() == pgx.ErrNoRows.Error():
+	case errors.IsErr(err, pgx.ErrNoRows):
 		return errors.KindNotFound
+	case errors.AsErr(err, &pgErr) && pgErr.Code == pgErrUniqueViolation:
+		return errors.KindAlreadyExists
 	default:
 		return errors.Kind(err)
 	}
-
-	// TODO (danielnegri): Clean-up native Postgres error handling.
-	//pqerr := &pq.Error{}
-	//if errors.AsErr(err, &pqerr) {
-	//	switch pqerr.Code {
-	//	case pgErrUniqueViolation:
-	//		return errors.KindAlreadyExists
-	//	default:
-	//		return errors.KindUnexpected
-	//	}
-	//}
-
 }
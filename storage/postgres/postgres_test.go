@@ -18,6 +18,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/insighted4/correios-cep/storage/storagetest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -62,3 +63,13 @@ func TestCheck(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestStorage(t *testing.T) {
+	if shouldSkip() {
+		t.SkipNow()
+	}
+
+	setup(t)
+
+	storagetest.Run(t, postgres)
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+)
+
+func (p *Postgres) CreateSearchResult(ctx context.Context, result *storage.SearchResult) error {
+	const op errors.Op = "postgres.CreateSearchResult"
+	query := `INSERT INTO search_results (
+				key,
+				ceps,
+				created_at
+			) VALUES ($1, $2, $3)
+			ON CONFLICT (key) DO UPDATE SET
+				ceps = EXCLUDED.ceps,
+				created_at = EXCLUDED.created_at;
+	`
+
+	if result == nil || result.Key == "" {
+		return errors.E(op, errors.KindBadRequest, "invalid search result")
+	}
+
+	now := p.now()
+	result.CreatedAt = &now
+
+	// Searches without results are recorded too, as an empty array.
+	ceps := result.CEPs
+	if ceps == nil {
+		ceps = []string{}
+	}
+
+	if _, err := p.db.Exec(ctx, query,
+		result.Key,
+		ceps,
+		result.CreatedAt,
+	); err != nil {
+		return errors.E(op, kind(err), err)
+	}
+
+	return nil
+}
+
+func (p *Postgres) GetSearchResult(ctx context.Context, key string) (*storage.SearchResult, error) {
+	const op errors.Op = "postgres.GetSearchResult"
+	query := `
+		SELECT
+			key,
+			ceps,
+			created_at
+		FROM search_results
+		WHERE
+			key = $1;
+	`
+
+	var result storage.SearchResult
+	if err := p.db.QueryRow(ctx, query, key).Scan(
+		&result.Key,
+		&result.CEPs,
+		&result.CreatedAt,
+	); err != nil {
+		return nil, errors.E(op, kind(err), err)
+	}
+
+	return &result, nil
+}
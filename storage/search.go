@@ -0,0 +1,76 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MinSearchQuery is the shortest query SearchAddresses accepts.
+const MinSearchQuery = 3
+
+// SearchParams selects the addresses whose city, neighborhood and location
+// contain every word of Query, ignoring case and accents.
+type SearchParams struct {
+	Query string
+	// State, when set, restricts the results to that state.
+	State string
+	Limit int
+}
+
+// Validate rejects queries too short to match usefully and invalid limits.
+func (p SearchParams) Validate() error {
+	const op errors.Op = "storage.SearchParams.Validate"
+
+	if utf8.RuneCountInString(strings.TrimSpace(p.Query)) < MinSearchQuery {
+		return errors.E(op, errors.KindBadRequest, fmt.Sprintf("query must have at least %d characters", MinSearchQuery))
+	}
+
+	if p.Limit < 1 {
+		return errors.E(op, errors.KindBadRequest, "limit must be positive")
+	}
+
+	return nil
+}
+
+// Terms returns the folded words of the query.
+func (p SearchParams) Terms() []string {
+	return strings.Fields(Fold(p.Query))
+}
+
+// Fold lower-cases s and strips its accents, so that "Goiânia" and
+// "goiania" compare equal.
+func Fold(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, s)
+	if err != nil {
+		folded = s
+	}
+
+	return strings.ToLower(folded)
+}
+
+// SearchText is the text of an address that searches match against.
+func SearchText(address *Address) string {
+	return Fold(address.City + " " + address.Neighborhood + " " + address.Location)
+}
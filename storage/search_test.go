@@ -0,0 +1,32 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFold(t *testing.T) {
+	assert.Equal(t, "goiania", Fold("Goiânia"))
+	assert.Equal(t, "sao joao do acu", Fold("SÃO JOÃO DO AÇU"))
+	assert.Equal(t, "avenida anhanguera", Fold("Avenida Anhangüera"))
+}
+
+func TestSearchParams_Terms(t *testing.T) {
+	p := SearchParams{Query: "  Setor  Universitário Goiânia "}
+	assert.Equal(t, []string{"setor", "universitario", "goiania"}, p.Terms())
+}
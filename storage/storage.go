@@ -16,6 +16,8 @@ package storage
 
 import (
 	"context"
+	"iter"
+	"time"
 )
 
 type Storage interface {
@@ -25,22 +27,83 @@ type Storage interface {
 	CreateAddress(ctx context.Context, address *Address) error
 	UpdateAddress(ctx context.Context, cep string, updater Updater) error
 	GetAddress(ctx context.Context, cep string) (*Address, error)
+	// BulkUpsertAddresses stores every address yielded by addresses,
+	// replacing the stored addresses with the same CEPs, in batches of
+	// BulkBatchSize. Batches are committed as they go, and progress, when
+	// set, is called with the running totals after each one. Within a batch,
+	// the last address yielded for a CEP wins.
+	BulkUpsertAddresses(ctx context.Context, addresses iter.Seq2[*Address, error], progress func(stats BulkStats)) (BulkStats, error)
+	// DeleteAddress removes the address of cep. It fails with KindNotFound
+	// when there is none.
+	DeleteAddress(ctx context.Context, cep string) error
 	ListAddresses(ctx context.Context, params ListParams) ([]*Address, error)
+	// SearchAddresses returns up to params.Limit addresses matching
+	// params, the most similar to the query first.
+	SearchAddresses(ctx context.Context, params SearchParams) ([]*Address, error)
+	// ExportAddresses returns every address matching params, ordered by CEP.
+	// Addresses are read as they are iterated, so memory stays flat however
+	// many are stored.
+	ExportAddresses(ctx context.Context, params ExportParams) iter.Seq2[*Address, error]
+	// ListStaleAddresses returns up to limit addresses last updated before
+	// the given time, oldest first.
+	ListStaleAddresses(ctx context.Context, before time.Time, limit int) ([]*Address, error)
+
+	// CreateNotFound records a CEP unknown upstream, replacing any previous
+	// record for the same CEP. Creating an address removes its record.
+	CreateNotFound(ctx context.Context, notFound *NotFound) error
+	// GetNotFound returns the record of a CEP unknown upstream. It fails with
+	// KindNotFound when there is none or it has expired.
+	GetNotFound(ctx context.Context, cep string) (*NotFound, error)
+
+	// CreateDeltaVersion records an applied eDNE delta. It fails with
+	// KindAlreadyExists when the version was already recorded.
+	CreateDeltaVersion(ctx context.Context, version *DeltaVersion) error
+	// GetDeltaVersion returns the record of an applied eDNE delta.
+	GetDeltaVersion(ctx context.Context, version string) (*DeltaVersion, error)
+
+	// CreateSearchResult records the CEPs an upstream address search
+	// answered, replacing any previous record for the same key.
+	CreateSearchResult(ctx context.Context, result *SearchResult) error
+	// GetSearchResult returns the record of an upstream address search. It
+	// fails with KindNotFound when there is none.
+	GetSearchResult(ctx context.Context, key string) (*SearchResult, error)
+
+	// WithTx calls fn with a storage whose changes are committed together
+	// when fn succeeds and discarded when it fails. The storage given to fn
+	// must not be used after fn returns.
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
 }
 
 type (
 	Updater func(old *Address) (*Address, error)
 
 	ListParams struct {
-		State      string
+		State string
+		// Type, when set, restricts the results to CEPs of that type.
+		Type       CEPType
 		Pagination *Pagination
 	}
+
+	ExportParams struct {
+		// State, when set, restricts the export to addresses of that state.
+		State string
+	}
 )
 
 const (
 	PaginationLimit = 100
+
+	// BulkBatchSize is the number of addresses BulkUpsertAddresses writes
+	// at once.
+	BulkBatchSize = 10000
 )
 
+// BulkStats counts the addresses written by BulkUpsertAddresses.
+type BulkStats struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
+
 // Pagination is passed as a parameter to limit the total of rows.
 type Pagination struct {
 	Limit  int
@@ -52,6 +115,10 @@ func NewPagination(perPage, page int) *Pagination {
 		perPage = PaginationLimit
 	}
 
+	if page < 0 {
+		page = 0
+	}
+
 	return &Pagination{
 		Limit:  perPage,
 		Offset: page * perPage,
@@ -31,3 +31,9 @@ func TestNewPaginationWithDefault(t *testing.T) {
 	assert.Equal(t, PaginationLimit, p.Limit)
 	assert.Equal(t, 0, p.Offset)
 }
+
+func TestNewPaginationWithNegativePage(t *testing.T) {
+	p := NewPagination(10, -1)
+	assert.Equal(t, 10, p.Limit)
+	assert.Equal(t, 0, p.Offset)
+}
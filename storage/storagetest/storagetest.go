@@ -0,0 +1,698 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storagetest provides a conformance suite that every
+// storage.Storage implementation is expected to pass.
+//
+// The suite only creates records with random CEPs and states, so it can run
+// against a database that already holds data. Backends must use the wall
+// clock, since not found records are created relative to time.Now.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/insighted4/correios-cep/pkg/errors"
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run runs the conformance suite against s.
+func Run(t *testing.T, s storage.Storage) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Storage)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"CreateAlreadyExists", testCreateAlreadyExists},
+		{"GetNotFound", testGetNotFound},
+		{"Update", testUpdate},
+		{"UpdateNotFound", testUpdateNotFound},
+		{"UpdateAlreadyExists", testUpdateAlreadyExists},
+		{"UpdaterError", testUpdaterError},
+		{"Delete", testDelete},
+		{"BulkUpsert", testBulkUpsert},
+		{"BulkUpsertError", testBulkUpsertError},
+		{"ListPagination", testListPagination},
+		{"ListStateCaseInsensitive", testListStateCaseInsensitive},
+		{"ListType", testListType},
+		{"ListInvalidParams", testListInvalidParams},
+		{"Search", testSearch},
+		{"SearchInvalidParams", testSearchInvalidParams},
+		{"Export", testExport},
+		{"ExportStop", testExportStop},
+		{"ListStale", testListStale},
+		{"ListStaleInvalidLimit", testListStaleInvalidLimit},
+		{"NotFound", testNotFound},
+		{"NotFoundExpired", testNotFoundExpired},
+		{"NotFoundClearedByCreate", testNotFoundClearedByCreate},
+		{"DeltaVersion", testDeltaVersion},
+		{"SearchResult", testSearchResult},
+		{"TxCommit", testTxCommit},
+		{"TxRollback", testTxRollback},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, s)
+		})
+	}
+}
+
+// NewAddress returns an address with random values and a single child.
+func NewAddress() *storage.Address {
+	return &storage.Address{
+		CEP:          gofakeit.UUID(),
+		State:        gofakeit.LoremIpsumWord(),
+		City:         gofakeit.City(),
+		Neighborhood: gofakeit.LoremIpsumWord(),
+		Location:     gofakeit.Street(),
+		Unit:         gofakeit.Company(),
+		Type:         storage.CEPTypes()[gofakeit.Number(0, len(storage.CEPTypes())-1)],
+		Complement:   gofakeit.LoremIpsumWord(),
+		Subordinate:  gofakeit.LoremIpsumWord(),
+		Status:       gofakeit.LoremIpsumWord(),
+		POBoxRanges: []storage.POBoxRange{
+			{Start: "1", End: gofakeit.DigitN(4)},
+		},
+		CEPRanges: []storage.CEPRange{
+			{Start: gofakeit.DigitN(8), End: gofakeit.DigitN(8)},
+		},
+		Street: gofakeit.Street(),
+		NumberRange: &storage.NumberRange{
+			Side: storage.SideOdd,
+			From: gofakeit.Number(1, 999),
+		},
+		Children: []*storage.Address{
+			{
+				CEP:          gofakeit.UUID(),
+				State:        gofakeit.LoremIpsumWord(),
+				City:         gofakeit.City(),
+				Neighborhood: gofakeit.LoremIpsumWord(),
+				Location:     gofakeit.Street(),
+			},
+		},
+	}
+}
+
+// assertAddress compares two addresses, allowing timestamps to differ by
+// the precision lost in the backend.
+func assertAddress(t *testing.T, expected, actual *storage.Address) {
+	t.Helper()
+
+	require.NotNil(t, actual)
+	require.NotNil(t, actual.CreatedAt)
+	require.NotNil(t, actual.UpdatedAt)
+	assert.WithinDuration(t, *expected.CreatedAt, *actual.CreatedAt, time.Millisecond)
+	assert.WithinDuration(t, *expected.UpdatedAt, *actual.UpdatedAt, time.Millisecond)
+
+	e, a := *expected, *actual
+	e.CreatedAt, e.UpdatedAt = nil, nil
+	a.CreatedAt, a.UpdatedAt = nil, nil
+	assert.Equal(t, e, a)
+}
+
+func testCreateAndGet(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	a1 := NewAddress()
+	require.NoError(t, s.CreateAddress(ctx, a1))
+	assert.NotNil(t, a1.CreatedAt)
+	assert.NotNil(t, a1.UpdatedAt)
+
+	a2, err := s.GetAddress(ctx, a1.CEP)
+	require.NoError(t, err)
+	assertAddress(t, a1, a2)
+}
+
+func testCreateAlreadyExists(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	a1 := NewAddress()
+	require.NoError(t, s.CreateAddress(ctx, a1))
+
+	a2 := NewAddress()
+	a2.CEP = a1.CEP
+	err := s.CreateAddress(ctx, a2)
+	assert.True(t, errors.Is(err, errors.KindAlreadyExists), "expected KindAlreadyExists, got %v", err)
+
+	a3, err := s.GetAddress(ctx, a1.CEP)
+	require.NoError(t, err)
+	assert.Equal(t, a1.City, a3.City)
+}
+
+func testGetNotFound(t *testing.T, s storage.Storage) {
+	address, err := s.GetAddress(context.Background(), gofakeit.UUID())
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+	assert.Nil(t, address)
+}
+
+func testUpdate(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	a0 := NewAddress()
+	require.NoError(t, s.CreateAddress(ctx, a0))
+
+	a1, err := s.GetAddress(ctx, a0.CEP)
+	require.NoError(t, err)
+
+	a2CEP := gofakeit.UUID()
+	updater := func(old *storage.Address) (*storage.Address, error) {
+		assert.Equal(t, a0.CEP, old.CEP)
+		updated := NewAddress()
+		updated.CEP = a2CEP
+		return updated, nil
+	}
+	require.NoError(t, s.UpdateAddress(ctx, a0.CEP, updater))
+
+	_, err = s.GetAddress(ctx, a0.CEP)
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+
+	a2, err := s.GetAddress(ctx, a2CEP)
+	require.NoError(t, err)
+	assert.NotEqual(t, a1.State, a2.State)
+	assert.NotEqual(t, a1.City, a2.City)
+	assert.NotEqual(t, a1.Neighborhood, a2.Neighborhood)
+	assert.NotEqual(t, a1.Location, a2.Location)
+	assert.NotEqual(t, a1.Children, a2.Children)
+	assert.WithinDuration(t, *a1.CreatedAt, *a2.CreatedAt, time.Millisecond)
+	assert.False(t, a2.UpdatedAt.Before(*a1.UpdatedAt))
+}
+
+func testUpdateNotFound(t *testing.T, s storage.Storage) {
+	called := false
+	err := s.UpdateAddress(context.Background(), gofakeit.UUID(), func(old *storage.Address) (*storage.Address, error) {
+		called = true
+		return old, nil
+	})
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+	assert.False(t, called)
+}
+
+func testUpdateAlreadyExists(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	a1 := NewAddress()
+	require.NoError(t, s.CreateAddress(ctx, a1))
+
+	a2 := NewAddress()
+	require.NoError(t, s.CreateAddress(ctx, a2))
+
+	err := s.UpdateAddress(ctx, a2.CEP, func(old *storage.Address) (*storage.Address, error) {
+		old.CEP = a1.CEP
+		return old, nil
+	})
+	assert.True(t, errors.Is(err, errors.KindAlreadyExists), "expected KindAlreadyExists, got %v", err)
+
+	a3, err := s.GetAddress(ctx, a2.CEP)
+	require.NoError(t, err)
+	assert.Equal(t, a2.City, a3.City)
+}
+
+func testUpdaterError(t *testing.T, s storage.Storage) {
+	const op errors.Op = "storagetest.testUpdaterError"
+	ctx := context.Background()
+
+	a1 := NewAddress()
+	require.NoError(t, s.CreateAddress(ctx, a1))
+
+	err := s.UpdateAddress(ctx, a1.CEP, func(old *storage.Address) (*storage.Address, error) {
+		old.City = gofakeit.City()
+		return nil, errors.E(op, errors.KindBadRequest, "rejected by updater")
+	})
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+
+	a2, err := s.GetAddress(ctx, a1.CEP)
+	require.NoError(t, err)
+	assertAddress(t, a1, a2)
+}
+
+// createInState creates n addresses sharing a random state and returns their
+// CEPs in ascending order.
+func createInState(t *testing.T, s storage.Storage, state string, n int) []string {
+	t.Helper()
+
+	prefix := gofakeit.UUID()
+	ceps := make([]string, n)
+	for i := 0; i < n; i++ {
+		address := NewAddress()
+		address.CEP = fmt.Sprintf("%s-%02d", prefix, i)
+		address.State = state
+		require.NoError(t, s.CreateAddress(context.Background(), address))
+		ceps[i] = address.CEP
+	}
+
+	return ceps
+}
+
+func listCEPs(t *testing.T, s storage.Storage, params storage.ListParams) []string {
+	t.Helper()
+
+	addresses, err := s.ListAddresses(context.Background(), params)
+	require.NoError(t, err)
+
+	ceps := make([]string, len(addresses))
+	for i, address := range addresses {
+		ceps[i] = address.CEP
+	}
+
+	return ceps
+}
+
+func testDelete(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	address := NewAddress()
+	require.NoError(t, s.CreateAddress(ctx, address))
+	require.NoError(t, s.DeleteAddress(ctx, address.CEP))
+
+	_, err := s.GetAddress(ctx, address.CEP)
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+
+	err = s.DeleteAddress(ctx, address.CEP)
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+}
+
+func testBulkUpsert(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	existing := NewAddress()
+	require.NoError(t, s.CreateAddress(ctx, existing))
+
+	created, err := s.GetAddress(ctx, existing.CEP)
+	require.NoError(t, err)
+
+	update := NewAddress()
+	update.CEP = existing.CEP
+
+	first, last := NewAddress(), NewAddress()
+	last.CEP = first.CEP
+
+	missing := NewAddress()
+	require.NoError(t, s.CreateNotFound(ctx, &storage.NotFound{CEP: missing.CEP, ExpiresAt: time.Now().Add(time.Hour)}))
+
+	var progress []storage.BulkStats
+	stats, err := s.BulkUpsertAddresses(ctx, addressSeq(nil, update, first, missing, last), func(stats storage.BulkStats) {
+		progress = append(progress, stats)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, storage.BulkStats{Created: 2, Updated: 1}, stats)
+	assert.Equal(t, []storage.BulkStats{stats}, progress)
+
+	got, err := s.GetAddress(ctx, existing.CEP)
+	require.NoError(t, err)
+	assert.Equal(t, update.Location, got.Location)
+	assert.Equal(t, created.CreatedAt.Unix(), got.CreatedAt.Unix())
+
+	got, err = s.GetAddress(ctx, first.CEP)
+	require.NoError(t, err)
+	assert.Equal(t, last.Location, got.Location)
+
+	_, err = s.GetNotFound(ctx, missing.CEP)
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+}
+
+func testBulkUpsertError(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	address := NewAddress()
+	_, err := s.BulkUpsertAddresses(ctx, addressSeq(errors.E(errors.Op("storagetest"), errors.KindBadRequest, "invalid record"), address), nil)
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+}
+
+// addressSeq yields addresses, then err when set.
+func addressSeq(err error, addresses ...*storage.Address) iter.Seq2[*storage.Address, error] {
+	return func(yield func(*storage.Address, error) bool) {
+		for _, address := range addresses {
+			if !yield(address, nil) {
+				return
+			}
+		}
+
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+func testListPagination(t *testing.T, s storage.Storage) {
+	state := gofakeit.UUID()
+	ceps := createInState(t, s, state, 5)
+
+	page := func(perPage, page int) []string {
+		return listCEPs(t, s, storage.ListParams{State: state, Pagination: storage.NewPagination(perPage, page)})
+	}
+
+	assert.Equal(t, ceps, page(0, 0))
+	assert.Equal(t, ceps[0:2], page(2, 0))
+	assert.Equal(t, ceps[2:4], page(2, 1))
+	assert.Equal(t, ceps[4:5], page(2, 2))
+	assert.Empty(t, page(2, 3))
+	assert.Equal(t, ceps, page(5, 0))
+	assert.Empty(t, page(5, 1))
+	assert.Equal(t, ceps[0:2], page(2, -1))
+}
+
+func testListStateCaseInsensitive(t *testing.T, s storage.Storage) {
+	state := strings.ToLower(gofakeit.UUID())
+	ceps := createInState(t, s, state, 2)
+
+	for _, variant := range []string{state, strings.ToUpper(state)} {
+		assert.Equal(t, ceps, listCEPs(t, s, storage.ListParams{State: variant, Pagination: storage.NewPagination(0, 0)}))
+	}
+}
+
+func testListType(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	state := gofakeit.UUID()
+
+	var streets []string
+	for i, cepType := range []storage.CEPType{storage.CEPTypeStreet, storage.CEPTypeBigUser, storage.CEPTypeStreet} {
+		address := NewAddress()
+		address.CEP = fmt.Sprintf("%s-%02d", state, i)
+		address.State = state
+		address.Type = cepType
+		require.NoError(t, s.CreateAddress(ctx, address))
+
+		if cepType == storage.CEPTypeStreet {
+			streets = append(streets, address.CEP)
+		}
+	}
+
+	params := storage.ListParams{State: state, Type: storage.CEPTypeStreet, Pagination: storage.NewPagination(0, 0)}
+	assert.Equal(t, streets, listCEPs(t, s, params))
+
+	params.Type = storage.CEPTypeUnit
+	assert.Empty(t, listCEPs(t, s, params))
+
+	params.Type = storage.CEPTypeUnknown
+	assert.Len(t, listCEPs(t, s, params), 3)
+}
+
+func testListInvalidParams(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.ListAddresses(ctx, storage.ListParams{Pagination: storage.NewPagination(0, 0)})
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+
+	_, err = s.ListAddresses(ctx, storage.ListParams{State: gofakeit.UUID()})
+	assert.Error(t, err)
+
+	_, err = s.ListAddresses(ctx, storage.ListParams{State: gofakeit.UUID(), Pagination: &storage.Pagination{Limit: 10, Offset: -1}})
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+}
+
+func testSearch(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	state := gofakeit.UUID()
+
+	create := func(suffix, city, neighborhood, location string) {
+		address := NewAddress()
+		address.CEP = state + "-" + suffix
+		address.State = state
+		address.City = city
+		address.Neighborhood = neighborhood
+		address.Location = location
+		require.NoError(t, s.CreateAddress(ctx, address))
+	}
+
+	create("1", "Goiânia", "Setor Central", "Avenida Anhangüera")
+	create("2", "Goiânia", "Setor Leste Universitário Expansão", "Praça Universitária Anhangüera")
+	create("3", "Anápolis", "Centro", "Rua Goiânia")
+	create("4", "São Paulo", "Sé", "Praça da Sé")
+
+	search := func(query string, limit int) []string {
+		addresses, err := s.SearchAddresses(ctx, storage.SearchParams{Query: query, State: strings.ToUpper(state), Limit: limit})
+		require.NoError(t, err)
+
+		ceps := make([]string, len(addresses))
+		for i, address := range addresses {
+			ceps[i] = strings.TrimPrefix(address.CEP, state+"-")
+		}
+
+		return ceps
+	}
+
+	assert.ElementsMatch(t, []string{"1", "2", "3"}, search("goiania", 10))
+	assert.Equal(t, []string{"1", "2"}, search("GOIANIA anhanguera", 10))
+	assert.Equal(t, []string{"1"}, search("GOIANIA anhanguera", 1))
+	assert.Equal(t, []string{"4"}, search("praca da se", 10))
+	assert.Empty(t, search("sao paulo anhanguera", 10))
+	assert.Empty(t, search("100%", 10))
+}
+
+func testSearchInvalidParams(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.SearchAddresses(ctx, storage.SearchParams{Query: "go", Limit: 10})
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+
+	_, err = s.SearchAddresses(ctx, storage.SearchParams{Query: "goiania"})
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+}
+
+func testExport(t *testing.T, s storage.Storage) {
+	state := strings.ToLower(gofakeit.UUID())
+	ceps := createInState(t, s, state, 3)
+
+	assert.Equal(t, ceps, exportCEPs(t, s, storage.ExportParams{State: strings.ToUpper(state)}))
+
+	all := exportCEPs(t, s, storage.ExportParams{})
+	assert.Subset(t, all, ceps)
+}
+
+func testExportStop(t *testing.T, s storage.Storage) {
+	state := gofakeit.UUID()
+	ceps := createInState(t, s, state, 3)
+
+	var got []string
+	for address, err := range s.ExportAddresses(context.Background(), storage.ExportParams{State: state}) {
+		require.NoError(t, err)
+		got = append(got, address.CEP)
+		break
+	}
+
+	assert.Equal(t, ceps[:1], got)
+}
+
+func exportCEPs(t *testing.T, s storage.Storage, params storage.ExportParams) []string {
+	t.Helper()
+
+	ceps := make([]string, 0)
+	for address, err := range s.ExportAddresses(context.Background(), params) {
+		require.NoError(t, err)
+		ceps = append(ceps, address.CEP)
+	}
+
+	return ceps
+}
+
+func testListStale(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	older := NewAddress()
+	require.NoError(t, s.CreateAddress(ctx, older))
+	time.Sleep(2 * time.Millisecond)
+	newer := NewAddress()
+	require.NoError(t, s.CreateAddress(ctx, newer))
+	require.True(t, newer.UpdatedAt.After(*older.UpdatedAt), "expected the backend to use the wall clock")
+
+	const limit = 1000
+	addresses, err := s.ListStaleAddresses(ctx, *newer.UpdatedAt, limit)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(addresses), limit)
+
+	var found bool
+	for i, address := range addresses {
+		assert.NotEqual(t, newer.CEP, address.CEP)
+		assert.True(t, address.UpdatedAt.Before(*newer.UpdatedAt))
+		if i > 0 {
+			assert.False(t, address.UpdatedAt.Before(*addresses[i-1].UpdatedAt), "expected oldest first")
+		}
+		found = found || address.CEP == older.CEP
+	}
+	if len(addresses) < limit {
+		assert.True(t, found, "expected %s to be stale", older.CEP)
+	}
+}
+
+func testListStaleInvalidLimit(t *testing.T, s storage.Storage) {
+	_, err := s.ListStaleAddresses(context.Background(), time.Now(), 0)
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+}
+
+func testNotFound(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	code := gofakeit.UUID()
+	_, err := s.GetNotFound(ctx, code)
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+
+	expiresAt := time.Now().Add(time.Hour)
+	require.NoError(t, s.CreateNotFound(ctx, &storage.NotFound{CEP: code, ExpiresAt: expiresAt}))
+
+	notFound, err := s.GetNotFound(ctx, code)
+	require.NoError(t, err)
+	assert.Equal(t, code, notFound.CEP)
+	assert.WithinDuration(t, expiresAt, notFound.ExpiresAt, time.Millisecond)
+	assert.NotNil(t, notFound.CreatedAt)
+
+	// Creating it again replaces the record.
+	expiresAt = expiresAt.Add(time.Hour)
+	require.NoError(t, s.CreateNotFound(ctx, &storage.NotFound{CEP: code, ExpiresAt: expiresAt}))
+
+	notFound, err = s.GetNotFound(ctx, code)
+	require.NoError(t, err)
+	assert.WithinDuration(t, expiresAt, notFound.ExpiresAt, time.Millisecond)
+}
+
+func testNotFoundExpired(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	code := gofakeit.UUID()
+	require.NoError(t, s.CreateNotFound(ctx, &storage.NotFound{CEP: code, ExpiresAt: time.Now().Add(-time.Minute)}))
+
+	notFound, err := s.GetNotFound(ctx, code)
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+	assert.Nil(t, notFound)
+}
+
+func testNotFoundClearedByCreate(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	address := NewAddress()
+	require.NoError(t, s.CreateNotFound(ctx, &storage.NotFound{CEP: address.CEP, ExpiresAt: time.Now().Add(time.Hour)}))
+	require.NoError(t, s.CreateAddress(ctx, address))
+
+	_, err := s.GetNotFound(ctx, address.CEP)
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+}
+
+func testSearchResult(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	key := gofakeit.UUID()
+	_, err := s.GetSearchResult(ctx, key)
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+
+	require.NoError(t, s.CreateSearchResult(ctx, &storage.SearchResult{Key: key}))
+
+	result, err := s.GetSearchResult(ctx, key)
+	require.NoError(t, err)
+	assert.Empty(t, result.CEPs)
+	assert.NotNil(t, result.CreatedAt)
+
+	// Creating it again replaces the record.
+	ceps := []string{gofakeit.UUID(), gofakeit.UUID()}
+	require.NoError(t, s.CreateSearchResult(ctx, &storage.SearchResult{Key: key, CEPs: ceps}))
+
+	result, err = s.GetSearchResult(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, ceps, result.CEPs)
+
+	err = s.CreateSearchResult(ctx, &storage.SearchResult{})
+	assert.True(t, errors.Is(err, errors.KindBadRequest), "expected KindBadRequest, got %v", err)
+}
+
+func testDeltaVersion(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	version := gofakeit.UUID()
+	_, err := s.GetDeltaVersion(ctx, version)
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+
+	require.NoError(t, s.CreateDeltaVersion(ctx, &storage.DeltaVersion{Version: version}))
+
+	applied, err := s.GetDeltaVersion(ctx, version)
+	require.NoError(t, err)
+	assert.Equal(t, version, applied.Version)
+	assert.NotNil(t, applied.AppliedAt)
+
+	err = s.CreateDeltaVersion(ctx, &storage.DeltaVersion{Version: version})
+	assert.True(t, errors.Is(err, errors.KindAlreadyExists), "expected KindAlreadyExists, got %v", err)
+}
+
+func testTxCommit(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	existing := NewAddress()
+	require.NoError(t, s.CreateAddress(ctx, existing))
+
+	created := NewAddress()
+	require.NoError(t, s.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.CreateAddress(ctx, created); err != nil {
+			return err
+		}
+
+		if err := tx.UpdateAddress(ctx, existing.CEP, func(old *storage.Address) (*storage.Address, error) {
+			old.City = "Goiânia"
+			return old, nil
+		}); err != nil {
+			return err
+		}
+
+		return tx.DeleteAddress(ctx, created.CEP)
+	}))
+
+	_, err := s.GetAddress(ctx, created.CEP)
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+
+	updated, err := s.GetAddress(ctx, existing.CEP)
+	require.NoError(t, err)
+	assert.Equal(t, "Goiânia", updated.City)
+}
+
+func testTxRollback(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	existing := NewAddress()
+	require.NoError(t, s.CreateAddress(ctx, existing))
+
+	created := NewAddress()
+	version := gofakeit.UUID()
+	err := s.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.CreateDeltaVersion(ctx, &storage.DeltaVersion{Version: version}); err != nil {
+			return err
+		}
+
+		if err := tx.CreateAddress(ctx, created); err != nil {
+			return err
+		}
+
+		if err := tx.DeleteAddress(ctx, existing.CEP); err != nil {
+			return err
+		}
+
+		return errors.E("storagetest", errors.KindUnexpected, "rollback")
+	})
+	assert.EqualError(t, err, "rollback")
+
+	_, err = s.GetAddress(ctx, created.CEP)
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+
+	_, err = s.GetDeltaVersion(ctx, version)
+	assert.True(t, errors.Is(err, errors.KindNotFound), "expected KindNotFound, got %v", err)
+
+	_, err = s.GetAddress(ctx, existing.CEP)
+	assert.NoError(t, err)
+}
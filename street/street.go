@@ -0,0 +1,108 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package street parses the ranges of house numbers that Correios appends
+// to street names, such as "Rua X - até 999/1000" or
+// "Avenida Y - de 1001 ao fim - lado ímpar".
+package street
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/insighted4/correios-cep/storage"
+)
+
+const separator = " - "
+
+const number = `(\d[\d.]*)(?:/(\d[\d.]*))?`
+
+var (
+	untilRe   = regexp.MustCompile(`^(?i)até ` + number + `$`)
+	fromRe    = regexp.MustCompile(`^(?i)de ` + number + ` ao fim$`)
+	betweenRe = regexp.MustCompile(`^(?i)de ` + number + ` a ` + number + `$`)
+	sideRe    = regexp.MustCompile(`^(?i)lado (ímpar|impar|par)$`)
+)
+
+// Parse splits a location into the street name and the range of numbers
+// covered by its CEP. The range is nil when the location has none, in which
+// case the name is the whole location.
+func Parse(location string) (string, *storage.NumberRange) {
+	parts := strings.Split(location, separator)
+
+	// The range is made of the trailing parts; the name itself may contain
+	// the separator.
+	for i := 1; i < len(parts); i++ {
+		if r, ok := parseRange(parts[i:]); ok {
+			return strings.TrimSpace(strings.Join(parts[:i], separator)), r
+		}
+	}
+
+	return strings.TrimSpace(location), nil
+}
+
+func parseRange(parts []string) (*storage.NumberRange, bool) {
+	r := &storage.NumberRange{Side: storage.SideBoth}
+	hasBounds, hasSide := false, false
+
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		switch {
+		case !hasBounds && untilRe.MatchString(part):
+			m := untilRe.FindStringSubmatch(part)
+			r.To = max(atoi(m[1]), atoi(m[2]))
+			hasBounds = true
+		case !hasBounds && fromRe.MatchString(part):
+			m := fromRe.FindStringSubmatch(part)
+			r.From = minPositive(atoi(m[1]), atoi(m[2]))
+			hasBounds = true
+		case !hasBounds && betweenRe.MatchString(part):
+			m := betweenRe.FindStringSubmatch(part)
+			r.From = minPositive(atoi(m[1]), atoi(m[2]))
+			r.To = max(atoi(m[3]), atoi(m[4]))
+			hasBounds = true
+		case !hasSide && sideRe.MatchString(part):
+			if strings.EqualFold(sideRe.FindStringSubmatch(part)[1], "par") {
+				r.Side = storage.SideEven
+			} else {
+				r.Side = storage.SideOdd
+			}
+			hasSide = true
+		default:
+			return nil, false
+		}
+	}
+
+	return r, hasBounds || hasSide
+}
+
+// atoi parses numbers written with thousands separators (e.g. "1.001"),
+// returning zero for empty values.
+func atoi(s string) int {
+	n, err := strconv.Atoi(strings.ReplaceAll(s, ".", ""))
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+func minPositive(a, b int) int {
+	if b == 0 {
+		return a
+	}
+
+	return min(a, b)
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 The Correios CEP Admin Authors
+//
+// Licensed under the AGPL, Version 3.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.gnu.org/licenses/agpl-3.0.en.html
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package street
+
+import (
+	"testing"
+
+	"github.com/insighted4/correios-cep/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		location string
+		name     string
+		r        *storage.NumberRange
+	}{
+		{"Rua X - até 999/1000", "Rua X", &storage.NumberRange{Side: storage.SideBoth, To: 1000}},
+		{"Avenida Y - de 1001 ao fim - lado ímpar", "Avenida Y", &storage.NumberRange{Side: storage.SideOdd, From: 1001}},
+		{"Avenida Y - de 1002/1003 ao fim", "Avenida Y", &storage.NumberRange{Side: storage.SideBoth, From: 1002}},
+		{"Rua Z - de 501 a 999 - lado ímpar", "Rua Z", &storage.NumberRange{Side: storage.SideOdd, From: 501, To: 999}},
+		{"Rua Z - de 500/501 a 998/999", "Rua Z", &storage.NumberRange{Side: storage.SideBoth, From: 500, To: 999}},
+		{"Rua Z - até 998 - lado par", "Rua Z", &storage.NumberRange{Side: storage.SideEven, To: 998}},
+		{"Rua Z - lado par", "Rua Z", &storage.NumberRange{Side: storage.SideEven}},
+		{"Rua Z - De 1.001 ao fim - Lado Impar", "Rua Z", &storage.NumberRange{Side: storage.SideOdd, From: 1001}},
+		{"Rua 10 - Setor Oeste - até 99", "Rua 10 - Setor Oeste", &storage.NumberRange{Side: storage.SideBoth, To: 99}},
+		{"Rua 10 - Setor Oeste", "Rua 10 - Setor Oeste", nil},
+		{"Rodovia GO-080", "Rodovia GO-080", nil},
+		{"Rua José Bonifácio, 24", "Rua José Bonifácio, 24", nil},
+		{"", "", nil},
+	}
+
+	for _, tt := range tests {
+		name, r := Parse(tt.location)
+		assert.Equal(t, tt.name, name, tt.location)
+		assert.Equal(t, tt.r, r, tt.location)
+	}
+}